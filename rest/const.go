@@ -7,6 +7,9 @@ const (
 	APIVersion = "0"
 )
 
+// MaxOrderBookDepth - maximum depth of order book returned by REST API
+const MaxOrderBookDepth = 500
+
 // Interval values
 const (
 	Interval1m  = 1
@@ -20,12 +23,36 @@ const (
 	Interval1M  = 21600
 )
 
+// System statuses returned by SystemStatus
+const (
+	SystemStatusOnline      = "online"
+	SystemStatusMaintenance = "maintenance"
+	SystemStatusCancelOnly  = "cancel_only"
+	SystemStatusPostOnly    = "post_only"
+)
+
+// Asset classes
+const (
+	AssetClassCurrency = "currency"
+	AssetClassFutures  = "futures"
+)
+
+// AssetPairs info levels
+const (
+	AssetPairsInfoAll      = "info"
+	AssetPairsInfoLeverage = "leverage"
+	AssetPairsInfoFees     = "fees"
+	AssetPairsInfoMargin   = "margin"
+)
+
 // Order Sides
 const (
 	TradeBuy  = "b"
 	TradeSell = "s"
 	Buy       = "buy"
 	Sell      = "sell"
+	SideBuy   = Buy
+	SideSell  = Sell
 )
 
 // Order types
@@ -91,6 +118,14 @@ const (
 	LedgerTypeRollover   = "rollover"
 )
 
+// Earn lock types
+const (
+	EarnLockFlex    = "flex"
+	EarnLockBonded  = "bonded"
+	EarnLockTimed   = "timed"
+	EarnLockInstant = "instant"
+)
+
 // OrderTypes for AddOrder
 const (
 	OTMarket              = "market"
@@ -107,6 +142,30 @@ const (
 	OTSettlePosition      = "settle-position"
 )
 
+// Order types accepted by AddOrder. Aliases of OT* constants.
+const (
+	OrderTypeMarket              = OTMarket
+	OrderTypeLimit               = OTLimit
+	OrderTypeStopLoss            = OTStopLoss
+	OrderTypeTakeProfit          = OTTakeProfi
+	OrderTypeStopLossProfit      = OTStopLossProfit
+	OrderTypeStopLossProfitLimit = OTStopLossProfitLimit
+	OrderTypeStopLossLimit       = OTStopLossLimit
+	OrderTypeTakeProfitLimit     = OTTakeProfitLimit
+	OrderTypeTrailingStop        = OTTrailingStop
+	OrderTypeTrailingStopLimit   = OTTrailingStopLimit
+	OrderTypeStopLossAndLimit    = OTStopLossAndLimit
+	OrderTypeSettlePosition      = OTSettlePosition
+)
+
+// Price kinds returned by ParseRelativePrice
+const (
+	PriceAbsolute = "absolute"
+	PriceRelative = "relative"
+	PricePercent  = "percent"
+	PricePegged   = "pegged"
+)
+
 // OrderStatuses
 const (
 	StatusPending   = "pending"
@@ -122,3 +181,33 @@ const (
 	OrderModeIOC = "IOC"
 	OrderModeGTD = "GTD"
 )
+
+// AddOrder args
+const (
+	ArgDeadline      = "deadline"
+	ArgDisplayVolume = "displayvol"
+	ArgSTPType       = "stptype"
+	ArgOrderFlags    = "oflags"
+	ArgClientOrderID = "cl_ord_id"
+	ArgUserRef       = "userref"
+	ArgValidate      = "validate"
+)
+
+// OrderFlag - flag of order passed in `oflags`
+type OrderFlag string
+
+// Order flags
+const (
+	OFlagVolumeInQuote OrderFlag = "viqc"
+	OFlagFeeInBase     OrderFlag = "fcib"
+	OFlagFeeInQuote    OrderFlag = "fciq"
+	OFlagNoMPP         OrderFlag = "nompp"
+	OFlagPostOnly      OrderFlag = "post"
+)
+
+// Self trade prevention types
+const (
+	STPCancelNewest = "cancel-newest"
+	STPCancelOldest = "cancel-oldest"
+	STPCancelBoth   = "cancel-both"
+)
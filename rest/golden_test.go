@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadGolden - reads captured Kraken response `testdata/<name>.json`
+func loadGolden(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		t.Fatalf("could not load golden file %s: %v", name, err)
+	}
+	return data
+}
+
+// decodeGolden - decodes result of captured Kraken response into `v` the same way as API methods do
+func decodeGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	api := &Kraken{}
+	resp := &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewReader(loadGolden(t, name))),
+	}
+	if err := api.parseResponse(resp, v); err != nil {
+		t.Fatalf("could not decode golden file %s: %v", name, err)
+	}
+}
+
+// assertPopulated - fails if `v` or any nested field of package types is zero. Golden files have every field set, so zero value means the field was lost by decoder.
+func assertPopulated(t *testing.T, path string, v interface{}, skip ...string) {
+	t.Helper()
+	skipped := make(map[string]bool, len(skip))
+	for _, name := range skip {
+		skipped[name] = true
+	}
+	checkPopulated(t, path, reflect.ValueOf(v), skipped)
+}
+
+var (
+	packagePath = reflect.TypeOf(Kraken{}).PkgPath()
+	decimalType = reflect.TypeOf(decimal.Big{})
+)
+
+func checkPopulated(t *testing.T, path string, v reflect.Value, skip map[string]bool) {
+	t.Helper()
+	if !v.IsValid() || v.IsZero() {
+		t.Errorf("%s is lost", path)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.Type().Elem() != decimalType {
+			checkPopulated(t, path, v.Elem(), skip)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			checkPopulated(t, fmt.Sprintf("%s[%d]", path, i), v.Index(i), skip)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			checkPopulated(t, fmt.Sprintf("%s[%v]", path, iter.Key().Interface()), iter.Value(), skip)
+		}
+	case reflect.Struct:
+		if v.Type().PkgPath() != packagePath {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || skip[field.Name] {
+				continue
+			}
+			checkPopulated(t, path+"."+field.Name, v.Field(i), skip)
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(t *testing.T)
+	}{
+		{
+			name: "ticker",
+			check: func(t *testing.T) {
+				response := make(map[string]Ticker)
+				decodeGolden(t, "ticker", &response)
+				assertPopulated(t, "ticker", response)
+			},
+		}, {
+			name: "ohlc",
+			check: func(t *testing.T) {
+				var response OHLCResponse
+				decodeGolden(t, "ohlc", &response)
+				assertPopulated(t, "ohlc", response)
+			},
+		}, {
+			name: "trades",
+			check: func(t *testing.T) {
+				var response TradeResponse
+				decodeGolden(t, "trades", &response)
+				assertPopulated(t, "trades", response, "Misc")
+			},
+		}, {
+			name: "spread",
+			check: func(t *testing.T) {
+				var response SpreadResponse
+				decodeGolden(t, "spread", &response)
+				assertPopulated(t, "spread.Last", response.Last)
+				assertPopulated(t, "spread.Pairs", response.Pairs)
+				assert.Equal(t, response.Pairs["XXBTZUSD"], response.XXBTZUSD)
+			},
+		}, {
+			name: "orderbook",
+			check: func(t *testing.T) {
+				response := make(map[string]OrderBook)
+				decodeGolden(t, "orderbook", &response)
+				assertPopulated(t, "orderbook", response)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NotPanics(t, func() {
+				tt.check(t)
+			})
+		})
+	}
+}
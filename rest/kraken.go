@@ -9,9 +9,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
@@ -23,23 +27,393 @@ type clientInterface interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-// Kraken - object wraps API
+// Kraken - object wraps API. Its methods are safe for concurrent use by multiple goroutines,
+// except setters (ForcePOST, OnWarning, OnResponseMeta) which must be called before requests are sent.
 type Kraken struct {
 	key    string
 	secret string
 	client clientInterface
+	ctx    context.Context //nolint:containedctx // parent context of requests, see WithContext
+	token  *tokenCache
+	fees   *feeCache
+	pairs  *pairCache
+	nonces *nonceCounter
+	cb     *circuitBreaker
+
+	forcePOST bool
+	onWarning func(warnings []string)
+	onMeta    func(status int, headers http.Header)
+
+	baseURL string
+	version string
+	timeout time.Duration
+	pause   time.Duration
+	logger  log.FieldLogger
+}
+
+// tokenCache - websockets token shared by copies of Kraken object
+type tokenCache struct {
+	mx        sync.Mutex
+	token     string
+	expiresAt time.Time
+	margin    time.Duration
+	now       func() time.Time
+}
+
+// feeCache - fee tiers by pair shared by copies of Kraken object
+type feeCache struct {
+	mx    sync.Mutex
+	tiers map[string]feeTier
+	now   func() time.Time
+}
+
+// pairCache - asset pairs info shared by copies of Kraken object.
+// Pairs not found after refetch are kept in `unknown` until the cache expires.
+type pairCache struct {
+	mx        sync.Mutex
+	pairs     map[string]AssetPair
+	unknown   map[string]bool
+	expiresAt time.Time
+	now       func() time.Time
+}
+
+// nonceCounter - last nonce of private requests shared by copies of Kraken object.
+// Kraken rejects nonce which is not greater than previous one, so concurrent requests must not reuse clock value.
+type nonceCounter struct {
+	mx   sync.Mutex
+	last int64
+}
+
+// next - returns current time in nanoseconds or previous nonce + 1 if clock didn't advance
+func (n *nonceCounter) next() int64 {
+	nonce := time.Now().UnixNano()
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if nonce <= n.last {
+		nonce = n.last + 1
+	}
+	n.last = nonce
+	return nonce
+}
+
+// feeTier - taker and maker fees in percents
+type feeTier struct {
+	taker     float64
+	maker     float64
+	expiresAt time.Time
+}
+
+// circuitBreaker - short-circuits requests for a cooldown after consecutive service errors. It's shared by copies of Kraken object
+type circuitBreaker struct {
+	mx        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	now       func() time.Time
 }
 
 // New - constructor of Kraken object
 func New(key string, secret string) *Kraken {
+	return NewWithOptions(key, secret)
+}
+
+// NewWithOptions - constructor of Kraken object configured by `opts`
+func NewWithOptions(key string, secret string, opts ...Option) *Kraken {
+	api := newKraken(key, secret, opts...)
 	if key == "" || secret == "" {
-		log.Print("[WARNING] You are not set api key and secret!")
+		api.getLogger().Print("[WARNING] You are not set api key and secret!")
 	}
-	return &Kraken{
+	return api
+}
+
+// NewPublic - constructor of Kraken object for public methods only. Unlike NewWithOptions it doesn't warn about missing api key and secret.
+func NewPublic(opts ...Option) *Kraken {
+	return newKraken("", "", opts...)
+}
+
+func newKraken(key string, secret string, opts ...Option) *Kraken {
+	api := &Kraken{
 		key:    key,
 		secret: secret,
 		client: http.DefaultClient,
+		token:  &tokenCache{},
+		fees:   &feeCache{tiers: make(map[string]feeTier)},
+		pairs:  &pairCache{},
+		nonces: &nonceCounter{},
+	}
+	for i := range opts {
+		opts[i](api)
+	}
+	return api
+}
+
+func (api *Kraken) apiURL() string {
+	if api.baseURL == "" {
+		return APIUrl
+	}
+	return api.baseURL
+}
+
+func (api *Kraken) apiVersion() string {
+	if api.version == "" {
+		return APIVersion
+	}
+	return api.version
+}
+
+func (api *Kraken) requestTimeout() time.Duration {
+	if api.timeout <= 0 {
+		return 30 * time.Second
 	}
+	return api.timeout
+}
+
+// batchPause - returns pause between batches of one request, see requestBatched
+func (api *Kraken) batchPause() time.Duration {
+	if api.pause <= 0 {
+		return time.Second
+	}
+	return api.pause
+}
+
+// parentContext - returns context which requests are bound to, see WithContext
+func (api *Kraken) parentContext() context.Context {
+	if api.ctx == nil {
+		return context.Background()
+	}
+	return api.ctx
+}
+
+// mergeContext - returns context derived from `ctx` with request timeout (see WithTimeout), which is also canceled when
+// context of Kraken object (see WithContext) is done. Returned cancel func must be called to release resources.
+func (api *Kraken) mergeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, api.requestTimeout())
+	parent := api.ctx
+	if parent == nil || parent.Done() == nil {
+		return ctx, cancel
+	}
+	go func() {
+		select {
+		case <-parent.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func (api *Kraken) getLogger() log.FieldLogger {
+	if api.logger == nil {
+		return log.StandardLogger()
+	}
+	return api.logger
+}
+
+// KrakenError - errors returned by Kraken in `error` field of response
+type KrakenError struct {
+	Errors []string
+}
+
+// Error -
+func (e *KrakenError) Error() string {
+	return fmt.Sprintf("kraken return errors: %s", e.Errors)
+}
+
+// IsServiceUnavailable - returns true if Kraken is in maintenance or overloaded (`EService:Unavailable`, `EService:Busy`)
+func (e *KrakenError) IsServiceUnavailable() bool {
+	for _, msg := range e.Errors {
+		if strings.HasPrefix(msg, "EService:Unavailable") || strings.HasPrefix(msg, "EService:Busy") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPostOnlyReject - returns true if post-only order was rejected because it would cross the book (`EOrder:Post only order`)
+func (e *KrakenError) IsPostOnlyReject() bool {
+	for _, msg := range e.Errors {
+		if strings.HasPrefix(msg, "EOrder:Post only order") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRateLimited - returns true if request was rejected by rate limiter (`EAPI:Rate limit exceeded`)
+func (e *KrakenError) IsRateLimited() bool {
+	for _, msg := range e.Errors {
+		if strings.HasPrefix(msg, "EAPI:Rate limit exceeded") {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrCircuitOpen - is returned without sending request while circuit breaker is open, see WithCircuitBreaker
+type ErrCircuitOpen struct {
+	Until time.Time
+}
+
+// Error -
+func (e *ErrCircuitOpen) Error() string {
+	return fmt.Sprintf("circuit breaker is open until %s: kraken service is unavailable", e.Until.Format(time.RFC3339))
+}
+
+// TruncatedBodyError - is returned if response body ends before complete JSON document is read, e.g. connection dropped mid-body. Such requests could be retried.
+type TruncatedBodyError struct {
+	Err error
+}
+
+// Error -
+func (e *TruncatedBodyError) Error() string {
+	return "error during response parsing: truncated response body: " + e.Err.Error()
+}
+
+// Unwrap - returns underlying cause
+func (e *TruncatedBodyError) Unwrap() error {
+	return e.Err
+}
+
+// maxParseErrorBody - maximum length of response body snippet kept in ParseError
+const maxParseErrorBody = 256
+
+// secretFieldRegexp - matches JSON string fields which could contain credentials, e.g. websocket token or withdrawal key
+var secretFieldRegexp = regexp.MustCompile(`(?i)("[a-z_]*(?:token|key|secret|address)[a-z_]*"\s*:\s*)"[^"]*"`)
+
+// ParseError - is returned if result of `Method` can not be decoded into response type, e.g. Kraken changed type of some field.
+// Body contains beginning of result with credentials redacted.
+type ParseError struct {
+	Method string
+	Body   string
+	Err    error
+}
+
+func newParseError(result []byte, err error) *ParseError {
+	body := secretFieldRegexp.ReplaceAllString(string(result), `$1"[REDACTED]"`)
+	if len(body) > maxParseErrorBody {
+		body = body[:maxParseErrorBody] + "..."
+	}
+	return &ParseError{Body: body, Err: err}
+}
+
+// Error -
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error during response parsing: json marshalling of %s result: %s (body: %s)", e.Method, e.Err, e.Body)
+}
+
+// Unwrap - returns underlying cause
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// IsTemporary - returns true if error is caused by transient transport condition (timeout, connection reset, etc.) and request could be retried
+func IsTemporary(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var truncated *TruncatedBodyError
+	if errors.As(err, &truncated) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// WithContext - returns shallow copy of Kraken object which requests are bound to `ctx`.
+// It allows a single deadline or cancellation to govern a sequence of calls. Each request still has its own timeout, see WithTimeout.
+func (api *Kraken) WithContext(ctx context.Context) *Kraken {
+	clone := *api
+	clone.ctx = ctx
+	return &clone
+}
+
+// ForcePOST - sends public requests with POST method and parameters in body if `b` is true. It's useful behind gateways which allow only POST requests.
+func (api *Kraken) ForcePOST(b bool) {
+	api.forcePOST = b
+}
+
+// OnWarning - sets `handler` called with non-fatal warnings returned by Kraken along with result, e.g. deprecation notices
+func (api *Kraken) OnWarning(handler func(warnings []string)) {
+	api.onWarning = handler
+}
+
+// OnResponseMeta - sets `handler` called with HTTP status and headers of every response, including failed ones.
+// It allows to monitor rate limit related headers and to capture request ids for support tickets.
+func (api *Kraken) OnResponseMeta(handler func(status int, headers http.Header)) {
+	api.onMeta = handler
+}
+
+// WithCircuitBreaker - returns shallow copy of Kraken object which stops sending requests for `cooldown` after `threshold` consecutive service errors (see KrakenError.IsServiceUnavailable).
+// Requests made while breaker is open return ErrCircuitOpen. The first request after cooldown is sent, its service error opens breaker again.
+func (api *Kraken) WithCircuitBreaker(threshold int, cooldown time.Duration) *Kraken {
+	clone := *api
+	clone.cb = &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+	return &clone
+}
+
+func (cb *circuitBreaker) allow() error {
+	cb.mx.Lock()
+	defer cb.mx.Unlock()
+
+	if cb.now().Before(cb.openUntil) {
+		return &ErrCircuitOpen{Until: cb.openUntil}
+	}
+	return nil
+}
+
+func (cb *circuitBreaker) record(err error) {
+	cb.mx.Lock()
+	defer cb.mx.Unlock()
+
+	var krakenErr *KrakenError
+	if !errors.As(err, &krakenErr) || !krakenErr.IsServiceUnavailable() {
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.failures >= cb.threshold {
+		cb.openUntil = cb.now().Add(cb.cooldown)
+	}
+}
+
+// Raw - calls any API `method` and returns raw `result` of response. It allows to call endpoints which are not wrapped by library yet.
+// Private requests are signed. `httpMethod` is `GET` or `POST`.
+// Request is also bounded by request timeout and context of Kraken object (see WithTimeout and WithContext).
+func (api *Kraken) Raw(ctx context.Context, method string, isPrivate bool, params map[string]string, httpMethod string) (json.RawMessage, error) {
+	ctx, cancel := api.mergeContext(ctx)
+	defer cancel()
+
+	data := url.Values{}
+	for key, value := range params {
+		data.Set(key, value)
+	}
+
+	var response json.RawMessage
+	if err := api.requestWithContext(ctx, method, isPrivate, data, &response, httpMethod); err != nil {
+		return nil, err
+	}
+	return response, nil
 }
 
 func (api *Kraken) getSign(requestURL string, data url.Values) (string, error) {
@@ -62,17 +436,37 @@ func (api *Kraken) getSign(requestURL string, data url.Values) (string, error) {
 	return base64.StdEncoding.EncodeToString(hmacData), nil
 }
 
+// Sign - returns `API-Sign` header value for private `method` (e.g. `AddOrder`) with request parameters `data`.
+// `data` must contain `nonce` which is always increasing for the API key (e.g. time.Now().UnixNano()), otherwise Kraken rejects request with `EAPI:Invalid nonce`.
+// Request body must be exactly `data.Encode()`.
+func (api *Kraken) Sign(method string, data url.Values) (apiSign string, err error) {
+	if data.Get("nonce") == "" {
+		return "", errors.New("nonce is required to sign request")
+	}
+	return api.getSign(fmt.Sprintf("/%s/private/%s", api.apiVersion(), method), data)
+}
+
+func (api *Kraken) nextNonce() int64 {
+	if api.nonces == nil {
+		return time.Now().UnixNano()
+	}
+	return api.nonces.next()
+}
+
 func (api *Kraken) prepareRequest(ctx context.Context, method string, isPrivate bool,
 	data url.Values, httpMethod string) (*http.Request, error) {
 	if data == nil {
 		data = url.Values{}
 	}
+	if api.forcePOST {
+		httpMethod = "POST"
+	}
 	requestURL := ""
 	if isPrivate {
-		requestURL = fmt.Sprintf("%s/%s/private/%s", APIUrl, APIVersion, method)
-		data.Set("nonce", fmt.Sprintf("%d", time.Now().UnixNano()))
+		requestURL = fmt.Sprintf("%s/%s/private/%s", api.apiURL(), api.apiVersion(), method)
+		data.Set("nonce", fmt.Sprintf("%d", api.nextNonce()))
 	} else {
-		requestURL = fmt.Sprintf("%s/%s/public/%s", APIUrl, APIVersion, method)
+		requestURL = fmt.Sprintf("%s/%s/public/%s", api.apiURL(), api.apiVersion(), method)
 	}
 	if httpMethod == "GET" {
 		requestURL = fmt.Sprintf("%s?%s", requestURL, data.Encode())
@@ -84,9 +478,8 @@ func (api *Kraken) prepareRequest(ctx context.Context, method string, isPrivate
 	}
 
 	if isPrivate {
-		urlPath := fmt.Sprintf("/%s/private/%s", APIVersion, method)
 		req.Header.Add("API-Key", api.key)
-		signature, err := api.getSign(urlPath, data)
+		signature, err := api.Sign(method, data)
 		if err != nil {
 			return nil, errors.Wrap(err, "invalid secret key")
 		}
@@ -96,6 +489,9 @@ func (api *Kraken) prepareRequest(ctx context.Context, method string, isPrivate
 }
 
 func (api *Kraken) parseResponse(response *http.Response, retType interface{}) error {
+	if api.onMeta != nil {
+		api.onMeta(response.StatusCode, response.Header)
+	}
 	if response.StatusCode != 200 {
 		return errors.Errorf("error during response parsing: invalid status code %d", response.StatusCode)
 	}
@@ -106,29 +502,69 @@ func (api *Kraken) parseResponse(response *http.Response, retType interface{}) e
 
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return &TruncatedBodyError{Err: err}
+		}
 		return errors.Wrap(err, "error during response parsing: can not read response body")
 	}
 
 	// log.Println(string(body))
-	var retData KrakenResponse
-	if retType != nil {
-		retData.Result = retType
+	var retData struct {
+		Error   []string        `json:"error"`
+		Result  json.RawMessage `json:"result"`
+		Warning []string        `json:"warning"`
 	}
-
 	if err = json.Unmarshal(body, &retData); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) && syntaxErr.Offset >= int64(len(body)) {
+			return &TruncatedBodyError{Err: err}
+		}
 		return errors.Wrap(err, "error during response parsing: json marshalling")
 	}
 
+	// errors are checked before result, because result could have unexpected shape if request failed
 	if len(retData.Error) > 0 {
-		return errors.Errorf("kraken return errors: %s", retData.Error)
+		return &KrakenError{Errors: retData.Error}
+	}
+	if len(retData.Warning) > 0 && api.onWarning != nil {
+		api.onWarning(retData.Warning)
 	}
 
+	if retType == nil || len(retData.Result) == 0 || string(retData.Result) == "null" {
+		return nil
+	}
+	if err = json.Unmarshal(retData.Result, retType); err != nil {
+		return newParseError(retData.Result, err)
+	}
 	return nil
 }
 
 func (api *Kraken) request(method string, isPrivate bool, data url.Values, retType interface{}, httpMethod string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	ctx, cancel := context.WithTimeout(api.parentContext(), api.requestTimeout())
 	defer cancel()
+	return api.requestWithContext(ctx, method, isPrivate, data, retType, httpMethod)
+}
+
+// do - requests `method` and decodes its result into a new value of type `T`. Decoded value is returned even on error.
+func do[T any](api *Kraken, method string, isPrivate bool, data url.Values, httpMethod string) (T, error) {
+	var result T
+	err := api.request(method, isPrivate, data, &result, httpMethod)
+	return result, err
+}
+
+func (api *Kraken) requestWithContext(ctx context.Context, method string, isPrivate bool, data url.Values, retType interface{}, httpMethod string) error {
+	if api.cb == nil {
+		return api.doRequest(ctx, method, isPrivate, data, retType, httpMethod)
+	}
+	if err := api.cb.allow(); err != nil {
+		return err
+	}
+	err := api.doRequest(ctx, method, isPrivate, data, retType, httpMethod)
+	api.cb.record(err)
+	return err
+}
+
+func (api *Kraken) doRequest(ctx context.Context, method string, isPrivate bool, data url.Values, retType interface{}, httpMethod string) error {
 	req, err := api.prepareRequest(ctx, method, isPrivate, data, httpMethod)
 	if err != nil {
 		return err
@@ -140,8 +576,13 @@ func (api *Kraken) request(method string, isPrivate bool, data url.Values, retTy
 	defer func(Body io.ReadCloser) {
 		err = Body.Close()
 		if err != nil {
-			log.Warnf("*Kraken request error : %s", err)
+			api.getLogger().Warnf("*Kraken request error : %s", err)
 		}
 	}(resp.Body)
-	return api.parseResponse(resp, retType)
+	err = api.parseResponse(resp, retType)
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.Method = method
+	}
+	return err
 }
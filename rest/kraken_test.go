@@ -3,12 +3,21 @@ package rest
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"path"
 	"reflect"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 func TestNew(t *testing.T) {
@@ -31,6 +40,10 @@ func TestNew(t *testing.T) {
 				key:    "",
 				secret: "",
 				client: http.DefaultClient,
+				token:  &tokenCache{},
+				fees:   &feeCache{tiers: make(map[string]feeTier)},
+				pairs:  &pairCache{},
+				nonces: &nonceCounter{},
 			},
 		},
 		{
@@ -43,6 +56,10 @@ func TestNew(t *testing.T) {
 				key:    "key",
 				secret: "secret",
 				client: http.DefaultClient,
+				token:  &tokenCache{},
+				fees:   &feeCache{tiers: make(map[string]feeTier)},
+				pairs:  &pairCache{},
+				nonces: &nonceCounter{},
 			},
 		},
 	}
@@ -132,6 +149,141 @@ const (
 	invalid  = "invalid"
 )
 
+func TestKraken_ParseError(t *testing.T) {
+	api := &Kraken{
+		client: &httpMock{
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"token":"secret-token","expires":"soon"}}`)),
+			},
+		},
+	}
+	_, err := api.GetWebSocketsToken()
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("Kraken.GetWebSocketsToken() error = %v, want ParseError", err)
+		return
+	}
+	if parseErr.Method != "GetWebSocketsToken" {
+		t.Errorf("ParseError.Method = %v, want GetWebSocketsToken", parseErr.Method)
+	}
+	if !strings.Contains(err.Error(), "GetWebSocketsToken") {
+		t.Errorf("ParseError.Error() = %v, want method name", err)
+	}
+	if strings.Contains(parseErr.Body, "secret-token") || !strings.Contains(parseErr.Body, `"expires":"soon"`) {
+		t.Errorf("ParseError.Body = %v, want redacted token", parseErr.Body)
+	}
+}
+
+// concurrentMock - client returning response by method name which could be used from several goroutines
+type concurrentMock struct {
+	mx        sync.Mutex
+	responses map[string]string
+	nonces    map[string]bool
+}
+
+func (c *concurrentMock) Do(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	if strings.Contains(req.URL.Path, "/private/") {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		c.mx.Lock()
+		c.nonces[values.Get("nonce")] = true
+		c.mx.Unlock()
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(strings.NewReader(c.responses[method])),
+	}, nil
+}
+
+func TestKraken_concurrentUse(t *testing.T) {
+	mock := &concurrentMock{
+		responses: map[string]string{
+			"Time":    `{"error":[],"result":{"unixtime":1554218108,"rfc1123":"Tue,  2 Apr 19 15:15:08 +0000"}}`,
+			"Ticker":  `{"error":[],"result":{"XXBTZUSD":{"o":"30306.1"}}}`,
+			"Trades":  `{"error":[],"result":{"XXBTZUSD":[["30306.1","0.5",1688671200.1234,"b","l","",61000001]],"last":"1688671200123400001"}}`,
+			"Balance": `{"error":[],"result":{"ZUSD":"100.0000"}}`,
+		},
+		nonces: make(map[string]bool),
+	}
+	api := New("key", deadbeaf)
+	api.client = mock
+
+	const goroutines = 100
+	var (
+		wg       sync.WaitGroup
+		errMx    sync.Mutex
+		errs     []error
+		balances int
+	)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			switch i % 4 {
+			case 0:
+				_, err = api.Time()
+			case 1:
+				_, err = api.Ticker("XXBTZUSD")
+			case 2:
+				_, err = api.GetTrades("XXBTZUSD", 0, 0)
+			case 3:
+				_, err = api.GetAccountBalances()
+			}
+			errMx.Lock()
+			defer errMx.Unlock()
+			if i%4 == 3 {
+				balances++
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		t.Errorf("concurrent requests failed: %v", errs)
+	}
+	if len(mock.nonces) != balances {
+		t.Errorf("got %d unique nonces of %d private requests", len(mock.nonces), balances)
+	}
+}
+
+func TestKraken_Sign(t *testing.T) {
+	api := New("key", "kQH5HW/8p1uGOVjbgWA7FunAmGO8lsSUXNsu3eow76sz84Q18fWxnyRzBHCd3pd5nE9qa99HAZtuZuj6F1huXg==")
+	data := url.Values{
+		"nonce":     {"1616492376594"},
+		"ordertype": {"limit"},
+		"pair":      {"XBTUSD"},
+		"price":     {"37500"},
+		"type":      {"buy"},
+		"volume":    {"1.25"},
+	}
+	got, err := api.Sign("AddOrder", data)
+	if err != nil {
+		t.Errorf("Kraken.Sign() error = %v", err)
+		return
+	}
+	want := "4/dpxb3iT4tp/ZCVEwSnEsLxx0bqyhLpdfOpc6fn7OR8+UClSV5n9E6aSS8MPtnRfp32bAb0nmbRn6H8ndwLUQ=="
+	if got != want {
+		t.Errorf("Kraken.Sign() = %v, want %v", got, want)
+	}
+
+	data.Del("nonce")
+	if _, err := api.Sign("AddOrder", data); err == nil {
+		t.Error("Kraken.Sign() without nonce error = nil, want error")
+	}
+}
+
 func TestKraken_prepareRequest(t *testing.T) {
 	type fields struct {
 		key        string
@@ -322,6 +474,34 @@ func TestKraken_parseResponse(t *testing.T) {
 			},
 			want:    nil,
 			wantErr: true,
+		}, {
+			name: "Kraken error with null result",
+			fields: fields{
+				key: "api-key",
+			},
+			args: args{
+				response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewBufferString(`{"error": ["EGeneral:Invalid arguments"], "result": null}`)),
+				},
+				retType: &TimeResponse{},
+			},
+			want:    nil,
+			wantErr: true,
+		}, {
+			name: "Kraken error with unexpected result shape",
+			fields: fields{
+				key: "api-key",
+			},
+			args: args{
+				response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewBufferString(`{"error": ["EGeneral:Invalid arguments"], "result": []}`)),
+				},
+				retType: &TimeResponse{},
+			},
+			want:    nil,
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
@@ -332,6 +512,57 @@ func TestKraken_parseResponse(t *testing.T) {
 				t.Errorf("Kraken.parseResponse() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.args.retType != nil && err != nil && !strings.Contains(err.Error(), "EGeneral:Invalid arguments") {
+				t.Errorf("Kraken.parseResponse() error = %v, want Kraken error", err)
+			}
+		})
+	}
+}
+
+// partialReader - returns data and then fails with err as if connection dropped mid-body
+type partialReader struct {
+	data []byte
+	err  error
+}
+
+func (r *partialReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestKraken_parseResponse_truncatedBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body io.Reader
+	}{
+		{
+			name: "connection dropped mid-body",
+			body: &partialReader{data: []byte(`{"error":[],"result":{"unixtime":16`), err: io.ErrUnexpectedEOF},
+		}, {
+			name: "body ends before JSON document",
+			body: bytes.NewBufferString(`{"error":[],"result":{"unixtime":16`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := New("api-key", deadbeaf)
+			err := api.parseResponse(&http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(tt.body),
+			}, &TimeResponse{})
+
+			var truncated *TruncatedBodyError
+			if !errors.As(err, &truncated) {
+				t.Errorf("Kraken.parseResponse() error = %v, want TruncatedBodyError", err)
+				return
+			}
+			if !IsTemporary(err) {
+				t.Errorf("IsTemporary(%v) = false, want true", err)
+			}
 		})
 	}
 }
@@ -379,3 +610,479 @@ func TestKraken_request(t *testing.T) {
 		})
 	}
 }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "timeout",
+			err:  &url.Error{Op: "Post", URL: APIUrl, Err: timeoutError{}},
+			want: true,
+		}, {
+			name: "connection reset",
+			err:  &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET},
+			want: true,
+		}, {
+			name: "permanent",
+			err:  ErrSomething,
+			want: false,
+		}, {
+			name: "nil",
+			err:  nil,
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err
+			if err != nil {
+				api := &Kraken{
+					client: &httpMock{
+						Response: &http.Response{},
+						Error:    tt.err,
+					},
+				}
+				err = api.request("Time", false, nil, nil, "GET")
+			}
+			if got := IsTemporary(err); got != tt.want {
+				t.Errorf("IsTemporary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type contextClient struct{}
+
+func (contextClient) Do(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(`{"error":[],"result":{}}`)),
+	}, nil
+}
+
+func TestKraken_WithContext(t *testing.T) {
+	api := &Kraken{
+		secret: deadbeaf,
+		client: contextClient{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bound := api.WithContext(ctx)
+
+	if _, err := bound.Time(); err != nil {
+		t.Errorf("Kraken.Time() error = %v", err)
+		return
+	}
+
+	cancel()
+	if _, err := bound.Time(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Kraken.Time() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := bound.GetAccountBalances(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Kraken.GetAccountBalances() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := api.Time(); err != nil {
+		t.Errorf("Kraken.Time() of original object error = %v", err)
+	}
+}
+
+func TestKraken_Raw(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"error":[],"result":{"foo":["bar",1]}}`)),
+		},
+	}
+	api := &Kraken{
+		key:    "api-key",
+		secret: deadbeaf,
+		client: mock,
+	}
+	got, err := api.Raw(context.Background(), "FakeMethod", true, map[string]string{"asset": "XBT"}, "POST")
+	if err != nil {
+		t.Errorf("Kraken.Raw() error = %v", err)
+		return
+	}
+	if string(got) != `{"foo":["bar",1]}` {
+		t.Errorf("Kraken.Raw() = %s, want %s", got, `{"foo":["bar",1]}`)
+	}
+	if mock.Request.URL.Path != "/0/private/FakeMethod" {
+		t.Errorf("Kraken.Raw() path = %s, want /0/private/FakeMethod", mock.Request.URL.Path)
+	}
+	if mock.Request.Header.Get("API-Sign") == "" {
+		t.Error("Kraken.Raw() request is not signed")
+	}
+}
+
+func TestKraken_Raw_context(t *testing.T) {
+	api := &Kraken{
+		client:  &httpBlockingMock{},
+		timeout: 10 * time.Millisecond,
+	}
+	if _, err := api.Raw(context.Background(), "Time", false, nil, "GET"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Kraken.Raw() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	api = api.WithContext(ctx)
+	api.timeout = time.Minute
+	if _, err := api.Raw(context.Background(), "Time", false, nil, "GET"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Kraken.Raw() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestKrakenError_IsServiceUnavailable(t *testing.T) {
+	tests := []struct {
+		name   string
+		errors []string
+		want   bool
+	}{
+		{name: "Unavailable", errors: []string{"EService:Unavailable"}, want: true},
+		{name: "Busy", errors: []string{"EGeneral:Temporary lockout", "EService:Busy"}, want: true},
+		{name: "Other error", errors: []string{"EAPI:Invalid nonce"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &KrakenError{Errors: tt.errors}
+			if got := e.IsServiceUnavailable(); got != tt.want {
+				t.Errorf("KrakenError.IsServiceUnavailable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKrakenError_IsPostOnlyReject(t *testing.T) {
+	tests := []struct {
+		name   string
+		errors []string
+		want   bool
+	}{
+		{name: "Post only", errors: []string{"EOrder:Post only order"}, want: true},
+		{name: "Among others", errors: []string{"EGeneral:Invalid arguments", "EOrder:Post only order"}, want: true},
+		{name: "Other error", errors: []string{"EOrder:Insufficient funds"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &KrakenError{Errors: tt.errors}
+			if got := e.IsPostOnlyReject(); got != tt.want {
+				t.Errorf("KrakenError.IsPostOnlyReject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKrakenError_IsRateLimited(t *testing.T) {
+	tests := []struct {
+		name   string
+		errors []string
+		want   bool
+	}{
+		{name: "Rate limit", errors: []string{"EAPI:Rate limit exceeded"}, want: true},
+		{name: "Among others", errors: []string{"EGeneral:Invalid arguments", "EAPI:Rate limit exceeded"}, want: true},
+		{name: "Other error", errors: []string{"EAPI:Invalid nonce"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &KrakenError{Errors: tt.errors}
+			if got := e.IsRateLimited(); got != tt.want {
+				t.Errorf("KrakenError.IsRateLimited() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKraken_WithCircuitBreaker(t *testing.T) {
+	unavailable := func() *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"error":["EService:Unavailable"]}`)),
+		}
+	}
+	mock := &httpSequenceMock{
+		Responses: []*http.Response{
+			unavailable(),
+			unavailable(),
+			{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"error":[],"result":{"unixtime":1554218108,"rfc1123":"Tue,  2 Apr 19 15:15:08 +0000"}}`)),
+			},
+		},
+	}
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	api := (&Kraken{client: mock}).WithCircuitBreaker(2, time.Minute)
+	api.cb.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := api.Time()
+		var krakenErr *KrakenError
+		if !errors.As(err, &krakenErr) || !krakenErr.IsServiceUnavailable() {
+			t.Errorf("Kraken.Time() error = %v, want service unavailable", err)
+		}
+	}
+
+	var circuitErr *ErrCircuitOpen
+	if _, err := api.Time(); !errors.As(err, &circuitErr) {
+		t.Errorf("Kraken.Time() error = %v, want ErrCircuitOpen", err)
+	} else if !circuitErr.Until.Equal(now.Add(time.Minute)) {
+		t.Errorf("ErrCircuitOpen.Until = %s, want %s", circuitErr.Until, now.Add(time.Minute))
+	}
+	if len(mock.Requests) != 2 {
+		t.Errorf("requests sent = %d, want 2", len(mock.Requests))
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := api.Time(); err != nil {
+		t.Errorf("Kraken.Time() after cooldown error = %v", err)
+	}
+	if api.cb.failures != 0 {
+		t.Errorf("failures after success = %d, want 0", api.cb.failures)
+	}
+}
+
+func TestKraken_ForcePOST(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"error":[],"result":{"XXBTZUSD":{"asks":[],"bids":[]}}}`)),
+		},
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	api.ForcePOST(true)
+
+	if _, err := api.GetOrderBook("XXBTZUSD", 10); err != nil {
+		t.Errorf("Kraken.GetOrderBook() error = %v", err)
+		return
+	}
+	if mock.Request.Method != "POST" {
+		t.Errorf("request method = %s, want POST", mock.Request.Method)
+	}
+	if mock.Request.URL.RawQuery != "" {
+		t.Errorf("request query = %s, want empty", mock.Request.URL.RawQuery)
+	}
+	body, err := io.ReadAll(mock.Request.Body)
+	if err != nil {
+		t.Errorf("could not read request body: %v", err)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Errorf("could not parse request body: %v", err)
+		return
+	}
+	if form.Get("pair") != "XXBTZUSD" || form.Get("count") != "10" {
+		t.Errorf("request body = %s, want pair and count", body)
+	}
+}
+
+func TestKraken_OnWarning(t *testing.T) {
+	api := &Kraken{
+		client: &httpMock{
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"error":[],"warning":["WGeneral:Deprecated parameter"],"result":{"unixtime":1554218108,"rfc1123":"Tue,  2 Apr 19 15:15:08 +0000"}}`)),
+			},
+		},
+	}
+	var warnings []string
+	api.OnWarning(func(w []string) {
+		warnings = append(warnings, w...)
+	})
+
+	got, err := api.Time()
+	if err != nil {
+		t.Errorf("Kraken.Time() error = %v", err)
+		return
+	}
+	if got.Unixtime != 1554218108 {
+		t.Errorf("Kraken.Time() unixtime = %d, want 1554218108", got.Unixtime)
+	}
+	if !reflect.DeepEqual(warnings, []string{"WGeneral:Deprecated parameter"}) {
+		t.Errorf("warnings = %v, want [WGeneral:Deprecated parameter]", warnings)
+	}
+}
+
+func TestKraken_OnResponseMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{
+			name:   "success",
+			status: 200,
+		}, {
+			name:    "rate limited",
+			status:  429,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &Kraken{
+				client: &httpMock{
+					Response: &http.Response{
+						StatusCode: tt.status,
+						Header:     http.Header{"X-Request-Id": {"5b1c6f0e-98a4"}},
+						Body:       io.NopCloser(bytes.NewBufferString(`{"error":[],"result":{"unixtime":1554218108,"rfc1123":"Tue,  2 Apr 19 15:15:08 +0000"}}`)),
+					},
+				},
+			}
+			var (
+				status  int
+				headers http.Header
+			)
+			api.OnResponseMeta(func(s int, h http.Header) {
+				status, headers = s, h
+			})
+
+			if _, err := api.Time(); (err != nil) != tt.wantErr {
+				t.Errorf("Kraken.Time() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+			if got := headers.Get("X-Request-Id"); got != "5b1c6f0e-98a4" {
+				t.Errorf("X-Request-Id header = %q, want 5b1c6f0e-98a4", got)
+			}
+		})
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNewPublic(t *testing.T) {
+	var output bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&output)
+
+	api := NewPublic(WithBaseURL("https://proxy.example.com"), WithLogger(logger))
+	if output.Len() != 0 {
+		t.Errorf("logger output = %q, want no warning", output.String())
+	}
+	if api.apiURL() != "https://proxy.example.com" {
+		t.Errorf("NewPublic() base URL = %s, want https://proxy.example.com", api.apiURL())
+	}
+	if api.pairs == nil || api.token == nil || api.nonces == nil {
+		t.Error("NewPublic() caches are not initialized")
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	var (
+		request  *http.Request
+		deadline time.Time
+	)
+	client := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			request = req
+			deadline, _ = req.Context().Deadline()
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"error":[],"result":{"unixtime":1554218108,"rfc1123":"Tue,  2 Apr 19 15:15:08 +0000"}}`)),
+			}, nil
+		}),
+	}
+	var output bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&output)
+
+	api := NewWithOptions("", "",
+		WithBaseURL("https://proxy.example.com"),
+		WithAPIVersion("1"),
+		WithHTTPClient(client),
+		WithTimeout(5*time.Second),
+		WithLogger(logger),
+	)
+	if !strings.Contains(output.String(), "You are not set api key and secret") {
+		t.Errorf("logger output = %q, want warning about keys", output.String())
+	}
+
+	if _, err := api.Time(); err != nil {
+		t.Errorf("Kraken.Time() error = %v", err)
+		return
+	}
+	if got := request.URL.String(); got != "https://proxy.example.com/1/public/Time?" {
+		t.Errorf("request URL = %s, want https://proxy.example.com/1/public/Time?", got)
+	}
+	if left := time.Until(deadline); left <= 0 || left > 5*time.Second {
+		t.Errorf("request timeout = %s, want at most 5s", left)
+	}
+}
+
+func TestKraken_do(t *testing.T) {
+	const body = `{"error":[],"result":{"eb":"1000.0000","tb":"800.0000","m":"0.0000","n":"0.0000","c":"0.0000","v":"0.0000","e":"800.0000","mf":"800.0000"}}`
+	tests := []struct {
+		name    string
+		body    string
+		err     error
+		wantErr bool
+	}{
+		{
+			name: "success",
+			body: body,
+		}, {
+			name:    "kraken error",
+			body:    `{"error":["EGeneral:Invalid arguments"]}`,
+			wantErr: true,
+		}, {
+			name:    "transport error",
+			err:     ErrSomething,
+			wantErr: true,
+		},
+	}
+	newAPI := func(body string, err error) (*Kraken, *httpMock) {
+		mock := &httpMock{
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(body)),
+			},
+			Error: err,
+		}
+		return &Kraken{key: "key", secret: deadbeaf, client: mock}, mock
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := url.Values{"asset": {"ZUSD"}}
+
+			api, mock := newAPI(tt.body, tt.err)
+			var want TradeBalanceResponse
+			wantErr := api.request("TradeBalance", true, data, &want, "POST")
+
+			api, doMock := newAPI(tt.body, tt.err)
+			got, err := do[TradeBalanceResponse](api, "TradeBalance", true, data, "POST")
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if fmt.Sprint(err) != fmt.Sprint(wantErr) {
+				t.Errorf("do() error = %v, want %v", err, wantErr)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("do() = %v, want %v", got, want)
+			}
+			if doMock.Request.Method != mock.Request.Method || doMock.Request.URL.String() != mock.Request.URL.String() {
+				t.Errorf("do() request = %s %s, want %s %s", doMock.Request.Method, doMock.Request.URL, mock.Request.Method, mock.Request.URL)
+			}
+		})
+	}
+}
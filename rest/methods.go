@@ -1,32 +1,79 @@
 package rest
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Time - Gets server time. Note: This is to aid in approximating the skew time between the server and client.
 func (api *Kraken) Time() (TimeResponse, error) {
+	return do[TimeResponse](api, "Time", false, nil, "GET")
+}
+
+// CheckClockDrift - compares server time with local clock and returns signed drift (server minus local).
+// Large drift is a common reason of invalid nonce errors. Server time has a second resolution.
+// Request is also bounded by request timeout and context of Kraken object (see WithTimeout and WithContext).
+func (api *Kraken) CheckClockDrift(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := api.mergeContext(ctx)
+	defer cancel()
+
 	response := TimeResponse{}
-	if err := api.request("Time", false, nil, &response, "GET"); err != nil {
-		return response, err
+	sent := time.Now()
+	if err := api.requestWithContext(ctx, "Time", false, nil, &response, "GET"); err != nil {
+		return 0, err
 	}
-	return response, nil
+	received := time.Now()
+	local := sent.Add(received.Sub(sent) / 2)
+	return time.Unix(response.Unixtime, 0).Sub(local), nil
+}
+
+// SystemStatus - Gets current system status or trading mode
+func (api *Kraken) SystemStatus() (SystemStatusResponse, error) {
+	return do[SystemStatusResponse](api, "SystemStatus", false, nil, "GET")
+}
+
+// Healthy - readiness probe. Returns true if system status is `online` and Time responds within `ctx` deadline.
+// Probe is also bounded by request timeout and context of Kraken object (see WithTimeout and WithContext).
+// Returns false without error on maintenance, cancel_only and other restricted trading modes.
+func (api *Kraken) Healthy(ctx context.Context) (bool, error) {
+	ctx, cancel := api.mergeContext(ctx)
+	defer cancel()
+
+	status := SystemStatusResponse{}
+	if err := api.requestWithContext(ctx, "SystemStatus", false, nil, &status, "GET"); err != nil {
+		return false, err
+	}
+	if status.Status != SystemStatusOnline {
+		return false, nil
+	}
+	if err := api.requestWithContext(ctx, "Time", false, nil, &TimeResponse{}, "GET"); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // Assets - Gets info about assets passed through `assets` arg.
 // `assets` - array of needed assets. All by default if empty array passed or `assets` is nil.
 func (api *Kraken) Assets(assets ...string) (map[string]Asset, error) {
+	return api.AssetsByClass("", assets...)
+}
+
+// AssetsByClass - Gets info about assets of asset class `aclass` passed through `assets` arg.
+// `aclass` - asset class (e.g. `currency`). Kraken's default if empty string passed.
+// `assets` - array of needed assets. All by default if empty array passed or `assets` is nil.
+func (api *Kraken) AssetsByClass(aclass string, assets ...string) (map[string]Asset, error) {
 	data := url.Values{}
-	if len(assets) > 0 {
-		data.Add("asset", strings.Join(assets, ","))
-	} else {
-		data = nil
+	if aclass != "" {
+		data.Add("aclass", aclass)
 	}
-	response := make(map[string]Asset)
-	if err := api.request("Assets", false, data, &response, "GET"); err != nil {
+	response, err := requestChunked[Asset](api, "Assets", "asset", assets, data)
+	if err != nil {
 		return response, err
 	}
 	return response, nil
@@ -35,38 +82,205 @@ func (api *Kraken) Assets(assets ...string) (map[string]Asset, error) {
 // AssetPairs - Gets array of pair names and their info passed through `pairs` arg.
 // `pairs` - array of needed pairs. All by default if empty array passed or `pairs` is nil.
 func (api *Kraken) AssetPairs(pairs ...string) (map[string]AssetPair, error) {
+	return api.AssetPairsInfo("", pairs...)
+}
+
+// AssetPairsInfo - Gets array of pair names and part of their info selected by `info` arg.
+// `info` - one of AssetPairsInfo* constants. Only fields of requested part are populated. Full info if empty string passed.
+// `pairs` - array of needed pairs. All by default if empty array passed or `pairs` is nil.
+func (api *Kraken) AssetPairsInfo(info string, pairs ...string) (map[string]AssetPair, error) {
 	data := url.Values{}
-	if len(pairs) > 0 {
-		data.Add("pair", strings.Join(pairs, ","))
-	} else {
-		data = nil
+	if info != "" {
+		data.Add("info", info)
 	}
-	response := make(map[string]AssetPair)
-	if err := api.request("AssetPairs", false, data, &response, "GET"); err != nil {
+	response, err := requestChunked[AssetPair](api, "AssetPairs", "pair", pairs, data)
+	if err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
+// pairCacheTTL - time while asset pairs used by PairInfo and DecimalsFor are cached
+const pairCacheTTL = time.Hour
+
+// DecimalsFor - returns price and lot decimals of `pair` for order formatting. `pair` could be pair name, altname or websocket name.
+// Asset pairs are cached, see PairInfo.
+func (api *Kraken) DecimalsFor(pair string) (priceDecimals, lotDecimals int, err error) {
+	_, info, err := api.PairInfo(pair)
+	if err != nil {
+		return 0, 0, err
+	}
+	return info.PairDecimals, info.LotDecimals, nil
+}
+
+// PairInfo - returns name and info of `pair` which could be pair name, altname or websocket name.
+// Asset pairs are cached for an hour. Unknown `pair` (e.g. newly listed) causes one refetch, then it's reported unknown until the cache expires.
+func (api *Kraken) PairInfo(pair string) (name string, info AssetPair, err error) {
+	cache := api.pairs
+	if cache == nil {
+		cache = &pairCache{}
+	}
+	cache.mx.Lock()
+	defer cache.mx.Unlock()
+
+	now := time.Now
+	if cache.now != nil {
+		now = cache.now
+	}
+	fetched := false
+	if cache.pairs == nil || !now().Before(cache.expiresAt) {
+		if err := api.refreshPairs(cache, now()); err != nil {
+			return "", AssetPair{}, err
+		}
+		fetched = true
+	}
+
+	name, info, ok := findAssetPair(cache.pairs, pair)
+	if !ok && !fetched && !cache.unknown[pair] {
+		if err := api.refreshPairs(cache, now()); err != nil {
+			return "", AssetPair{}, err
+		}
+		name, info, ok = findAssetPair(cache.pairs, pair)
+	}
+	if !ok {
+		cache.unknown[pair] = true
+		return "", AssetPair{}, fmt.Errorf("unknown pair %s", pair)
+	}
+	return name, info, nil
+}
+
+// refreshPairs - refetches asset pairs into `cache` and forgets unknown pairs. Caller must hold cache lock.
+func (api *Kraken) refreshPairs(cache *pairCache, now time.Time) error {
+	pairs, err := api.AssetPairs()
+	if err != nil {
+		return err
+	}
+	cache.pairs = pairs
+	cache.unknown = make(map[string]bool)
+	cache.expiresAt = now.Add(pairCacheTTL)
+	return nil
+}
+
+func findAssetPair(pairs map[string]AssetPair, pair string) (string, AssetPair, bool) {
+	if info, ok := pairs[pair]; ok {
+		return pair, info, true
+	}
+	for name, info := range pairs {
+		if info.Altname == pair || info.WSName == pair {
+			return name, info, true
+		}
+	}
+	return "", AssetPair{}, false
+}
+
 // Ticker - Gets array of tickers passed through `pairs` arg.
 // `pairs` - array of needed pairs. All by default if empty array passed or `pairs` is nil.
 func (api *Kraken) Ticker(pairs ...string) (map[string]Ticker, error) {
-	var data url.Values
-	if len(pairs) > 0 {
-		data = url.Values{
-			"pair": {strings.Join(pairs, ",")},
-		}
-	} else {
+	if len(pairs) == 0 {
 		return nil, errors.New("you need to set pairs on Ticker request")
 	}
-	response := make(map[string]Ticker)
-	if err := api.request("Ticker", false, data, &response, "GET"); err != nil {
+	response, err := requestChunked[Ticker](api, "Ticker", "pair", pairs, url.Values{})
+	if err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
-// Candles - Get OHLC data
+// maxListLength - practical limit of comma-joined list length in one request. Longer lists are split across several requests.
+const maxListLength = 1800
+
+// postListLength - comma-joined list length above which list is sent in POST body instead of GET query
+const postListLength = 512
+
+// chunkList - splits `items` into groups which comma-joined length doesn't exceed `maxLength`. Item longer than `maxLength` forms its own group.
+func chunkList(items []string, maxLength int) [][]string {
+	var (
+		chunks [][]string
+		chunk  []string
+		length int
+	)
+	for _, item := range items {
+		added := len(item)
+		if len(chunk) > 0 {
+			added++
+		}
+		if len(chunk) > 0 && length+added > maxLength {
+			chunks = append(chunks, chunk)
+			chunk, length, added = nil, 0, len(item)
+		}
+		chunk = append(chunk, item)
+		length += added
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// requestChunked - requests public `method` with `items` passed as comma-joined `key` parameter along with `data`.
+// Oversized lists are split by chunkList and results are merged into one map. All items are requested if `items` is empty.
+// Chunks longer than postListLength are sent in POST body, Kraken accepts POST for public endpoints.
+func requestChunked[T any](api *Kraken, method, key string, items []string, data url.Values) (map[string]T, error) {
+	chunks := chunkList(items, maxListLength)
+	if len(chunks) == 0 {
+		chunks = [][]string{nil}
+	}
+	response := make(map[string]T)
+	for _, chunk := range chunks {
+		values := url.Values{}
+		for k, v := range data {
+			values[k] = v
+		}
+		httpMethod := "GET"
+		if len(chunk) > 0 {
+			list := strings.Join(chunk, ",")
+			values.Set(key, list)
+			if len(list) > postListLength {
+				httpMethod = "POST"
+			}
+		}
+		if len(values) == 0 {
+			values = nil
+		}
+		part := make(map[string]T)
+		if err := api.request(method, false, values, &part, httpMethod); err != nil {
+			return response, err
+		}
+		for k, v := range part {
+			response[k] = v
+		}
+	}
+	return response, nil
+}
+
+// TickerOne - returns ticker of single `pair`
+func (api *Kraken) TickerOne(pair string) (Ticker, error) {
+	response, err := api.Ticker(pair)
+	if err != nil {
+		return Ticker{}, err
+	}
+	_, ticker, ok := FirstResult(response)
+	if !ok {
+		return Ticker{}, fmt.Errorf("expected single ticker for pair %s, got %d", pair, len(response))
+	}
+	return ticker, nil
+}
+
+// FirstResult - returns key and value of the single entry of one-pair response map (Ticker, GetOrderBook, etc.).
+// Kraken keys results by canonical pair name, which may differ from requested one. Returns false if map has not exactly one entry.
+func FirstResult[T any](m map[string]T) (string, T, bool) {
+	if len(m) == 1 {
+		for key, value := range m {
+			return key, value, true
+		}
+	}
+	var zero T
+	return "", zero, false
+}
+
+// Candles - Get OHLC data.
+// `since` - returns only candles committed after this cursor. Pass `Last` of previous response to poll new candles. Omitted if zero.
+// The last candle of each pair is still forming: it's returned regardless of `since` and its values change until interval ends.
 func (api *Kraken) Candles(pair string, interval int64, since int64) (OHLCResponse, error) {
 	data := url.Values{
 		"pair": {pair},
@@ -84,11 +298,30 @@ func (api *Kraken) Candles(pair string, interval int64, since int64) (OHLCRespon
 	return response, nil
 }
 
-// GetOrderBook - Gets order book for `pair` with `depth`
+// CandlesOne - returns candles of single `pair` and `last` cursor. See Candles for arguments.
+func (api *Kraken) CandlesOne(pair string, interval int64, since int64) ([]Candle, int64, error) {
+	response, err := api.Candles(pair, interval, since)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, candles, ok := FirstResult(response.Candles)
+	if !ok {
+		return nil, 0, fmt.Errorf("expected candles of single pair %s, got %d", pair, len(response.Candles))
+	}
+	return candles, response.Last, nil
+}
+
+// GetOrderBook - Gets order book for `pair` with `depth`.
+// `depth` - count of levels from 1 to 500. Kraken's default (100) is used if 0 passed.
 func (api *Kraken) GetOrderBook(pair string, depth int64) (map[string]OrderBook, error) {
+	if depth < 0 || depth > MaxOrderBookDepth {
+		return nil, fmt.Errorf("order book depth must be between 1 and %d, got %d", MaxOrderBookDepth, depth)
+	}
 	data := url.Values{
-		"pair":  {pair},
-		"count": {strconv.FormatInt(depth, 10)},
+		"pair": {pair},
+	}
+	if depth > 0 {
+		data.Set("count", strconv.FormatInt(depth, 10))
 	}
 	response := make(map[string]OrderBook)
 	if err := api.request("Depth", false, data, &response, "GET"); err != nil {
@@ -97,13 +330,27 @@ func (api *Kraken) GetOrderBook(pair string, depth int64) (map[string]OrderBook,
 	return response, nil
 }
 
-// GetTrades - returns trades on pair from since date, since can be either a unix timestamp or unix nano timestamp
-func (api *Kraken) GetTrades(pair string, since int64, count int64) (TradeResponse, error) {
+// GetOrderBookOne - returns order book of single `pair`. See GetOrderBook for `depth` details.
+func (api *Kraken) GetOrderBookOne(pair string, depth int64) (OrderBook, error) {
+	response, err := api.GetOrderBook(pair, depth)
+	if err != nil {
+		return OrderBook{}, err
+	}
+	_, book, ok := FirstResult(response)
+	if !ok {
+		return OrderBook{}, fmt.Errorf("expected order book of single pair %s, got %d", pair, len(response))
+	}
+	return book, nil
+}
+
+// GetTrades - returns trades on pair from since cursor. Kraken treats `since` as a trade-id cursor which is a unix nano timestamp,
+// so pass `Last` of the previous response or TradesCursor of a point in time.
+func (api *Kraken) GetTrades(pair string, since Cursor, count int64) (TradeResponse, error) {
 	data := url.Values{
 		"pair": {pair},
 	}
 	if since > 0 {
-		data.Add("since", strconv.FormatInt(since, 10))
+		data.Add("since", since.String())
 	}
 	if count == 0 || count > 1000 {
 		count = 1000
@@ -117,13 +364,191 @@ func (api *Kraken) GetTrades(pair string, since int64, count int64) (TradeRespon
 	return response, nil
 }
 
-// GetSpread - return array of pair name and recent spread data
-func (api *Kraken) GetSpread(pair string, since int64) (SpreadResponse, error) {
+// GetTradesMulti - returns trades grouped by pair and shared `last` cursor. See GetTrades for `since` details.
+func (api *Kraken) GetTradesMulti(pairs []string, since Cursor) (map[string][]Trade, Cursor, error) {
+	if len(pairs) == 0 {
+		return nil, 0, errors.New("you need to set pairs on Trades request")
+	}
+	data := url.Values{
+		"pair": {strings.Join(pairs, ",")},
+	}
+	if since > 0 {
+		data.Add("since", since.String())
+	}
+
+	response := make(map[string]json.RawMessage)
+	if err := api.request("Trades", false, data, &response, "GET"); err != nil {
+		return nil, 0, err
+	}
+
+	var last Cursor
+	trades := make(map[string][]Trade)
+	for key, raw := range response {
+		if key == "last" {
+			if err := json.Unmarshal(raw, &last); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		items := make([]Trade, 0)
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, 0, err
+		}
+		if items == nil {
+			items = make([]Trade, 0)
+		}
+		trades[key] = items
+	}
+	return trades, last, nil
+}
+
+// GetTradesFromTime - returns trades on pair starting from `from` time. The time is converted to the nanosecond cursor Kraken expects for `since`.
+func (api *Kraken) GetTradesFromTime(pair string, from time.Time, count int64) (TradeResponse, error) {
+	return api.GetTrades(pair, TradesCursor(from), count)
+}
+
+// BackfillOptions - options of BackfillTrades
+type BackfillOptions struct {
+	// Until - trades after this time are not fetched. Zero value means up to the latest trade.
+	Until time.Time
+	// Pause - delay between pages to stay within public API rate limits. If rate limit is exceeded, request is retried after
+	// backoff which starts from Pause (at least 1s) and doubles on every consecutive retry up to 1m.
+	Pause time.Duration
+	// MaxRetries - maximum count of consecutive retries after rate limit is exceeded. Zero value means 5.
+	MaxRetries int
+	// Progress - optional callback called after each page with total count of fetched trades and time of the last one
+	Progress func(fetched int, lastTime float64)
+	// FullHistory - confirms crawl from the oldest available trade. It's required if `since` is zero, because full history of liquid pair takes a lot of requests.
+	FullHistory bool
+	// MaxPages - maximum count of page requests, retries after rate limit included. Backfill halts with resumable cursor when it's reached.
+	// Zero value means no limit.
+	MaxPages int
+}
+
+// BackfillTrades - fetches trades of `pair` page by page starting from `since` cursor and passes every page to `handler`.
+// It stops when there are no new trades, `opts.Until` or `opts.MaxPages` is reached. Returned cursor could be passed as `since` to resume backfill.
+// Zero `since` is rejected unless `opts.FullHistory` is set.
+func (api *Kraken) BackfillTrades(ctx context.Context, pair string, since Cursor, opts BackfillOptions, handler func(Trades) error) (Cursor, error) {
+	if since == 0 && !opts.FullHistory {
+		return since, errors.New("backfill from zero cursor crawls full history: set `since` or BackfillOptions.FullHistory")
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultBackfillRetries
+	}
+	client := api.WithContext(ctx)
+	fetched := 0
+	retries := 0
+	for requests := 0; opts.MaxPages == 0 || requests < opts.MaxPages; requests++ {
+		response, err := client.GetTrades(pair, since, 0)
+		if err != nil {
+			if !isRateLimitError(err) {
+				return since, err
+			}
+			if retries >= maxRetries {
+				return since, fmt.Errorf("rate limit is still exceeded after %d retries: %w", retries, err)
+			}
+			if opts.MaxPages > 0 && requests+1 >= opts.MaxPages {
+				return since, nil
+			}
+			if err := sleepContext(ctx, backfillBackoff(opts.Pause, retries)); err != nil {
+				return since, err
+			}
+			retries++
+			continue
+		}
+		retries = 0
+
+		trades := response.Trades
+		done := false
+		if !opts.Until.IsZero() {
+			until := float64(opts.Until.UnixNano()) / float64(time.Second)
+			for i := range trades {
+				if trades[i].Time > until {
+					trades = trades[:i]
+					done = true
+					break
+				}
+			}
+		}
+		if len(trades) > 0 {
+			if err := handler(trades); err != nil {
+				return since, err
+			}
+			fetched += len(trades)
+			if opts.Progress != nil {
+				opts.Progress(fetched, trades[len(trades)-1].Time)
+			}
+		}
+
+		if done {
+			return TradesCursor(opts.Until), nil
+		}
+		if len(response.Trades) == 0 {
+			return since, nil
+		}
+		if response.Last <= since {
+			return since, nil
+		}
+		since = response.Last
+
+		if err := sleepContext(ctx, opts.Pause); err != nil {
+			return since, err
+		}
+	}
+	return since, nil
+}
+
+// Backoff of BackfillTrades after rate limit is exceeded
+const (
+	defaultBackfillRetries = 5
+	minBackfillBackoff     = time.Second
+	maxBackfillBackoff     = time.Minute
+)
+
+// backfillBackoff - returns delay before retry number `retry` (starting from zero) which doubles `pause` on every retry
+func backfillBackoff(pause time.Duration, retry int) time.Duration {
+	backoff := pause
+	if backoff < minBackfillBackoff {
+		backoff = minBackfillBackoff
+	}
+	for i := 0; i < retry && backoff < maxBackfillBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackfillBackoff {
+		backoff = maxBackfillBackoff
+	}
+	return backoff
+}
+
+func isRateLimitError(err error) bool {
+	var krakenErr *KrakenError
+	return errors.As(err, &krakenErr) && krakenErr.IsRateLimited()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// GetSpread - return array of pair name and recent spread data.
+// Kraken requires single `pair` on Spread request, spreads of all pairs can't be requested at once.
+// `since` is a Spread cursor, see SpreadCursor.
+func (api *Kraken) GetSpread(pair string, since Cursor) (SpreadResponse, error) {
+	if pair == "" {
+		return SpreadResponse{}, errors.New("you need to set pair on Spread request")
+	}
 	data := url.Values{
 		"pair": {pair},
 	}
 	if since > 0 {
-		data.Add("since", strconv.FormatInt(since, 10))
+		data.Add("since", since.String())
 	}
 	response := SpreadResponse{}
 	if err := api.request("Spread", false, data, &response, "GET"); err != nil {
@@ -131,3 +556,38 @@ func (api *Kraken) GetSpread(pair string, since int64) (SpreadResponse, error) {
 	}
 	return response, nil
 }
+
+// GetSpreadDecimal - returns recent spread data of pair with exact bid and ask prices and `last` cursor
+func (api *Kraken) GetSpreadDecimal(pair string, since Cursor) (map[string][]SpreadDecimal, Cursor, error) {
+	if pair == "" {
+		return nil, 0, errors.New("you need to set pair on Spread request")
+	}
+	data := url.Values{
+		"pair": {pair},
+	}
+	if since > 0 {
+		data.Add("since", since.String())
+	}
+
+	response := make(map[string]json.RawMessage)
+	if err := api.request("Spread", false, data, &response, "GET"); err != nil {
+		return nil, 0, err
+	}
+
+	var last Cursor
+	spreads := make(map[string][]SpreadDecimal)
+	for key, raw := range response {
+		if key == "last" {
+			if err := json.Unmarshal(raw, &last); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		var items []SpreadDecimal
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, 0, err
+		}
+		spreads[key] = items
+	}
+	return spreads, last, nil
+}
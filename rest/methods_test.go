@@ -2,11 +2,14 @@ package rest
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ericlagergren/decimal"
 	"github.com/stretchr/testify/assert"
@@ -17,15 +20,39 @@ var ErrSomething = fmt.Errorf("something went wrong")
 type httpMock struct {
 	Response *http.Response
 	Error    error
+	Request  *http.Request
 }
 
 func (c *httpMock) Do(req *http.Request) (*http.Response, error) {
+	c.Request = req
 	if c.Error != nil {
 		return c.Response, c.Error
 	}
 	return c.Response, nil
 }
 
+// httpSequenceMock - client returning next of Responses, or error at the same index of Errors if it's set
+type httpSequenceMock struct {
+	Responses []*http.Response
+	Errors    []error
+	Requests  []*http.Request
+}
+
+func (c *httpSequenceMock) Do(req *http.Request) (*http.Response, error) {
+	c.Requests = append(c.Requests, req)
+	if len(c.Responses) == 0 {
+		return nil, ErrSomething
+	}
+	resp := c.Responses[0]
+	c.Responses = c.Responses[1:]
+	var err error
+	if len(c.Errors) > 0 {
+		err = c.Errors[0]
+		c.Errors = c.Errors[1:]
+	}
+	return resp, err
+}
+
 func TestKraken_Time(t *testing.T) {
 	json := []byte(`{"error":[],"result":{"unixtime":1554218108,"rfc1123":"Tue,  2 Apr 19 15:15:08 +0000"}}`)
 	tests := []struct {
@@ -76,6 +103,128 @@ func TestKraken_Time(t *testing.T) {
 	}
 }
 
+func TestKraken_CheckClockDrift(t *testing.T) {
+	serverTime := time.Now().Add(time.Hour).Unix()
+	json := []byte(fmt.Sprintf(`{"error":[],"result":{"unixtime":%d,"rfc1123":""}}`, serverTime))
+	api := &Kraken{
+		client: &httpMock{
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewReader(json)),
+			},
+		},
+	}
+	got, err := api.CheckClockDrift(context.Background())
+	if err != nil {
+		t.Errorf("Kraken.CheckClockDrift() error = %v", err)
+		return
+	}
+	assert.InDelta(t, time.Hour.Seconds(), got.Seconds(), 2)
+}
+
+// httpBlockingMock - client blocking until request context is done
+type httpBlockingMock struct{}
+
+func (c *httpBlockingMock) Do(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestKraken_Healthy_context(t *testing.T) {
+	api := &Kraken{
+		client:  &httpBlockingMock{},
+		timeout: 10 * time.Millisecond,
+	}
+	_, err := api.Healthy(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	api = api.WithContext(ctx)
+	api.timeout = time.Minute
+	_, err = api.Healthy(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestKraken_CheckClockDrift_context(t *testing.T) {
+	api := &Kraken{
+		client:  &httpBlockingMock{},
+		timeout: 10 * time.Millisecond,
+	}
+	_, err := api.CheckClockDrift(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	api = api.WithContext(ctx)
+	api.timeout = time.Minute
+	_, err = api.CheckClockDrift(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestKraken_Healthy(t *testing.T) {
+	response := func(body string) *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+		}
+	}
+	tests := []struct {
+		name      string
+		responses []*http.Response
+		want      bool
+		wantErr   bool
+		requests  int
+	}{
+		{
+			name: "online",
+			responses: []*http.Response{
+				response(`{"error":[],"result":{"status":"online","timestamp":"2023-01-01T00:00:00Z"}}`),
+				response(`{"error":[],"result":{"unixtime":1672531200,"rfc1123":"Sun,  1 Jan 23 00:00:00 +0000"}}`),
+			},
+			want:     true,
+			requests: 2,
+		}, {
+			name: "maintenance",
+			responses: []*http.Response{
+				response(`{"error":[],"result":{"status":"maintenance","timestamp":"2023-01-01T00:00:00Z"}}`),
+			},
+			want:     false,
+			requests: 1,
+		}, {
+			name: "cancel only",
+			responses: []*http.Response{
+				response(`{"error":[],"result":{"status":"cancel_only","timestamp":"2023-01-01T00:00:00Z"}}`),
+			},
+			want:     false,
+			requests: 1,
+		}, {
+			name: "time fails",
+			responses: []*http.Response{
+				response(`{"error":[],"result":{"status":"online","timestamp":"2023-01-01T00:00:00Z"}}`),
+			},
+			want:     false,
+			wantErr:  true,
+			requests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpSequenceMock{Responses: tt.responses}
+			api := &Kraken{client: mock}
+			got, err := api.Healthy(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Kraken.Healthy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			if assert.Len(t, mock.Requests, tt.requests) {
+				assert.Contains(t, mock.Requests[0].URL.Path, "SystemStatus")
+			}
+		})
+	}
+}
+
 func TestKraken_Assets(t *testing.T) {
 	json := []byte(`{"error":[],"result":{"ADA":{"aclass":"currency","altname":"ADA","decimals":8,"display_decimals":6}}}`)
 	type args struct {
@@ -160,6 +309,27 @@ func TestKraken_Assets(t *testing.T) {
 	}
 }
 
+func TestKraken_AssetsByClass(t *testing.T) {
+	json := []byte(`{"error":[],"result":{"ADA":{"aclass":"currency","altname":"ADA","decimals":8,"display_decimals":6}}}`)
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(json)),
+		},
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	got, err := api.AssetsByClass(AssetClassCurrency, "ADA")
+	if err != nil {
+		t.Errorf("Kraken.AssetsByClass() error = %v", err)
+		return
+	}
+	assert.Equal(t, "currency", mock.Request.URL.Query().Get("aclass"))
+	assert.Equal(t, "ADA", mock.Request.URL.Query().Get("asset"))
+	assert.Equal(t, "currency", got["ADA"].AssetClass)
+}
+
 func TestKraken_AssetPairs(t *testing.T) {
 	json := []byte(`{"error":[],"result":{"ADACAD":{"altname":"ADACAD","wsname":"ADA\/CAD","aclass_base":"currency","base":"ADA","aclass_quote":"currency","quote":"ZCAD","lot":"unit","pair_decimals":6,"lot_decimals":8,"lot_multiplier":1,"leverage_buy":[],"leverage_sell":[],"fees":[[0,0.26],[50000,0.24],[100000,0.22],[250000,0.2],[500000,0.18],[1000000,0.16],[2500000,0.14],[5000000,0.12],[10000000,0.1]],"fees_maker":[[0,0.16],[50000,0.14],[100000,0.12],[250000,0.1],[500000,0.08],[1000000,0.06],[2500000,0.04],[5000000,0.02],[10000000,0]],"fee_volume_currency":"ZUSD","margin_call":80,"margin_stop":40}}}`)
 	type args struct {
@@ -268,6 +438,169 @@ func TestKraken_AssetPairs(t *testing.T) {
 	}
 }
 
+func TestKraken_AssetPairsInfo(t *testing.T) {
+	json := []byte(`{"error":[],"result":{"ADACAD":{"fees":[[0,0.26],[50000,0.24]],"fees_maker":[[0,0.16],[50000,0.14]],"fee_volume_currency":"ZUSD"}}}`)
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(json)),
+		},
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	got, err := api.AssetPairsInfo(AssetPairsInfoFees, "ADACAD")
+	if err != nil {
+		t.Errorf("Kraken.AssetPairsInfo() error = %v", err)
+		return
+	}
+	assert.Equal(t, "fees", mock.Request.URL.Query().Get("info"))
+	assert.Equal(t, "ADACAD", mock.Request.URL.Query().Get("pair"))
+	want := map[string]AssetPair{
+		"ADACAD": {
+			Fees:              [][]float64{{0, 0.26}, {50000, 0.24}},
+			FeesMaker:         [][]float64{{0, 0.16}, {50000, 0.14}},
+			FeeVolumeCurrency: "ZUSD",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Kraken.AssetPairsInfo() = %v, want %v", got, want)
+	}
+}
+
+func TestKraken_DecimalsFor(t *testing.T) {
+	mock := &httpSequenceMock{
+		Responses: []*http.Response{{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"XXBTZUSD":{"altname":"XBTUSD","wsname":"XBT/USD","pair_decimals":1,"lot_decimals":8},"XETHZUSD":{"altname":"ETHUSD","wsname":"ETH/USD","pair_decimals":2,"lot_decimals":8}}}`)),
+		}},
+	}
+	api := &Kraken{
+		client: mock,
+		pairs:  &pairCache{},
+	}
+
+	for _, pair := range []string{"XXBTZUSD", "XBT/USD"} {
+		priceDecimals, lotDecimals, err := api.DecimalsFor(pair)
+		if err != nil {
+			t.Errorf("Kraken.DecimalsFor() error = %v", err)
+			return
+		}
+		assert.Equal(t, 1, priceDecimals)
+		assert.Equal(t, 8, lotDecimals)
+	}
+	priceDecimals, _, err := api.DecimalsFor("ETHUSD")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, priceDecimals)
+
+	name, info, err := api.PairInfo("ETH/USD")
+	assert.Nil(t, err)
+	assert.Equal(t, "XETHZUSD", name)
+	assert.Equal(t, "ETHUSD", info.Altname)
+	assert.Len(t, mock.Requests, 1)
+}
+
+func TestKraken_DecimalsFor_expiration(t *testing.T) {
+	pairs := func() *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"XXBTZUSD":{"altname":"XBTUSD","wsname":"XBT/USD","pair_decimals":1,"lot_decimals":8}}}`)),
+		}
+	}
+	mock := &httpSequenceMock{
+		Responses: []*http.Response{pairs(), pairs(), pairs()},
+	}
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	api := &Kraken{
+		client: mock,
+		pairs:  &pairCache{now: func() time.Time { return now }},
+	}
+
+	_, _, err := api.DecimalsFor("XXBTZUSD")
+	assert.Nil(t, err)
+	assert.Len(t, mock.Requests, 1)
+
+	// unknown pair is refetched once, then it's cached as unknown
+	for i := 0; i < 3; i++ {
+		_, _, err = api.DecimalsFor("NEWUSD")
+		assert.NotNil(t, err)
+	}
+	assert.Len(t, mock.Requests, 2)
+
+	now = now.Add(pairCacheTTL)
+	_, _, err = api.DecimalsFor("NEWUSD")
+	assert.NotNil(t, err)
+	_, _, err = api.DecimalsFor("XXBTZUSD")
+	assert.Nil(t, err)
+	assert.Len(t, mock.Requests, 3)
+}
+
+func TestFirstResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		m         map[string]int
+		wantKey   string
+		wantValue int
+		wantOk    bool
+	}{
+		{
+			name:   "empty",
+			m:      map[string]int{},
+			wantOk: false,
+		}, {
+			name:      "single",
+			m:         map[string]int{"XXBTZUSD": 1},
+			wantKey:   "XXBTZUSD",
+			wantValue: 1,
+			wantOk:    true,
+		}, {
+			name:   "multi",
+			m:      map[string]int{"XXBTZUSD": 1, "XETHZUSD": 2},
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, value, ok := FirstResult(tt.m)
+			assert.Equal(t, tt.wantKey, key)
+			assert.Equal(t, tt.wantValue, value)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
+
+func TestKraken_OneMethods(t *testing.T) {
+	newAPI := func(body string) *Kraken {
+		return &Kraken{
+			client: &httpMock{
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+				},
+			},
+		}
+	}
+
+	ticker, err := newAPI(`{"error":[],"result":{"XXBTZUSD":{"c":["30306.1","0.001"],"o":"30000.0"}}}`).TickerOne("BTCUSD")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "30306.1", ticker.Close.Price.String())
+	}
+
+	book, err := newAPI(`{"error":[],"result":{"XXBTZUSD":{"asks":[["30306.1","1.5",1688671200]],"bids":[["30306.0","2",1688671200]]}}}`).GetOrderBookOne("BTCUSD", 1)
+	if assert.Nil(t, err) && assert.Len(t, book.Asks, 1) {
+		assert.Equal(t, 30306.1, book.Asks[0].Price)
+	}
+
+	candles, last, err := newAPI(`{"error":[],"result":{"XXBTZUSD":[[1688671200,"30306.1","30306.2","30305.7","30305.7","30306.1","3.39243896",23]],"last":1688672160}}`).CandlesOne("BTCUSD", 1, 0)
+	if assert.Nil(t, err) {
+		assert.Len(t, candles, 1)
+		assert.Equal(t, int64(1688672160), last)
+	}
+
+	_, err = newAPI(`{"error":[],"result":{"XXBTZUSD":{"c":["30306.1","0.001"]},"XETHZUSD":{"c":["1900.1","0.001"]}}}`).TickerOne("BTCUSD")
+	assert.NotNil(t, err)
+}
+
 func TestKraken_Ticker(t *testing.T) {
 	json := []byte(`{"error":[],"result":{"ADACAD":{"a":["0.108312","6418","6418.000"],"b":["0.090125","2688","2688.000"],"c":["0.090043","0.00000091"],"v":["115805.23341809","136512.79974015"],"p":["0.102010","0.100786"],"t":[54,67],"l":["0.090000","0.090000"],"h":["0.109000","0.109000"],"o":"0.093911"}}}`)
 	type args struct {
@@ -398,11 +731,113 @@ func TestKraken_Ticker(t *testing.T) {
 
 				assert.Equal(t, data.High.Price.String(), wantData.High.Price.String())
 				assert.Equal(t, data.High.LotVolume.String(), wantData.High.LotVolume.String())
+
+				assert.Equal(t, data.OpeningPrice.String(), wantData.OpeningPrice.String())
 			}
 		})
 	}
 }
 
+func TestKraken_AssetsPostBody(t *testing.T) {
+	assets := make([]string, 300)
+	result := make([]string, len(assets))
+	for i := range assets {
+		assets[i] = fmt.Sprintf("A%03d", i)
+		result[i] = fmt.Sprintf(`"%s":{"aclass":"currency","altname":"%s","decimals":8,"display_decimals":6}`, assets[i], assets[i])
+	}
+	mock := &httpSequenceMock{
+		Responses: []*http.Response{
+			{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{` + strings.Join(result, ",") + `}}`)),
+			},
+		},
+	}
+	api := &Kraken{
+		client: mock,
+	}
+
+	got, err := api.Assets(assets...)
+	if err != nil {
+		t.Errorf("Kraken.Assets() error = %v", err)
+		return
+	}
+	assert.Len(t, got, len(assets))
+	if !assert.Len(t, mock.Requests, 1) {
+		return
+	}
+	req := mock.Requests[0]
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "", req.URL.RawQuery)
+	assert.Equal(t, strings.Join(assets, ","), requestForm(t, req).Get("asset"))
+}
+
+func TestKraken_TickerChunked(t *testing.T) {
+	pairs := make([]string, 400)
+	for i := range pairs {
+		pairs[i] = fmt.Sprintf("PAIR%04dUSD", i)
+	}
+	chunks := chunkList(pairs, maxListLength)
+	assert.True(t, len(chunks) > 1)
+
+	mock := &httpSequenceMock{}
+	for _, chunk := range chunks {
+		result := make([]string, len(chunk))
+		for i, pair := range chunk {
+			result[i] = fmt.Sprintf(`"%s":{"o":"1.5"}`, pair)
+		}
+		mock.Responses = append(mock.Responses, &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{` + strings.Join(result, ",") + `}}`)),
+		})
+	}
+	api := &Kraken{
+		client: mock,
+	}
+
+	got, err := api.Ticker(pairs...)
+	if err != nil {
+		t.Errorf("Kraken.Ticker() error = %v", err)
+		return
+	}
+	assert.Len(t, got, len(pairs))
+	assert.Len(t, mock.Requests, len(chunks))
+	requested := 0
+	for _, req := range mock.Requests {
+		list := requestForm(t, req).Get("pair")
+		assert.True(t, len(list) <= maxListLength)
+		requested += len(strings.Split(list, ","))
+	}
+	assert.Equal(t, len(pairs), requested)
+	assert.Equal(t, "1.5", got["PAIR0399USD"].OpeningPrice.String())
+}
+
+func TestKraken_CandlesSince(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"XXBTZUSD":[[1688671200,"30306.1","30306.2","30305.7","30305.7","30306.1","3.1",5],[1688671260,"30305.7","30310.0","30305.0","30309.9","30308.0","1.2",3]],"last":1688671200}}`)),
+		},
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	got, err := api.Candles("XXBTZUSD", Interval1m, 1688671140)
+	if err != nil {
+		t.Errorf("Kraken.Candles() error = %v", err)
+		return
+	}
+	assert.Equal(t, "1688671140", mock.Request.URL.Query().Get("since"))
+	assert.Equal(t, "", mock.Request.URL.Query().Get("interval"))
+	assert.Equal(t, int64(1688671200), got.Last)
+	candles := got.Candles["XXBTZUSD"]
+	if !assert.Len(t, candles, 2) {
+		return
+	}
+	assert.Equal(t, int64(1688671200), candles[0].Time)
+	assert.Equal(t, int64(1688671260), candles[1].Time)
+}
+
 func TestKraken_Candles(t *testing.T) {
 	json := []byte(`{"error":[],"result":{"ADACAD":[[1554179640,"0.0005000","0.0005000","0.0005000","0.0005000","0.0000000","0.00000000",0]],"last":1554222360}}`)
 	response := OHLCResponse{
@@ -553,26 +988,38 @@ func TestKraken_GetOrderBook(t *testing.T) {
 				"ADACAD": {
 					Asks: []OrderBookItem{
 						{
-							Price:     0.109441,
-							Volume:    6741.072,
-							Timestamp: 1554223624,
+							Price:        0.109441,
+							Volume:       6741.072,
+							RawPrice:     "0.109441",
+							RawVolume:    "6741.072",
+							Timestamp:    1554223624,
+							RawTimestamp: "1554223624",
 						},
 						{
-							Price:     0.109442,
-							Volume:    4950.724,
-							Timestamp: 1554223614,
+							Price:        0.109442,
+							Volume:       4950.724,
+							RawPrice:     "0.109442",
+							RawVolume:    "4950.724",
+							Timestamp:    1554223614,
+							RawTimestamp: "1554223614",
 						},
 					},
 					Bids: []OrderBookItem{
 						{
-							Price:     0.090494,
-							Volume:    2789.652,
-							Timestamp: 1554223622,
+							Price:        0.090494,
+							Volume:       2789.652,
+							RawPrice:     "0.090494",
+							RawVolume:    "2789.652",
+							Timestamp:    1554223622,
+							RawTimestamp: "1554223622",
 						},
 						{
-							Price:     0.090493,
-							Volume:    6379.886,
-							Timestamp: 1554223620,
+							Price:        0.090493,
+							Volume:       6379.886,
+							RawPrice:     "0.090493",
+							RawVolume:    "6379.886",
+							Timestamp:    1554223620,
+							RawTimestamp: "1554223620",
 						},
 					},
 				},
@@ -600,11 +1047,61 @@ func TestKraken_GetOrderBook(t *testing.T) {
 	}
 }
 
+func TestKraken_GetOrderBookDepth(t *testing.T) {
+	json := []byte(`{"error":[],"result":{"ADACAD":{"asks":[],"bids":[]}}}`)
+	tests := []struct {
+		name      string
+		depth     int64
+		wantCount string
+		wantErr   bool
+	}{
+		{
+			name:    "depth is too large",
+			depth:   1000,
+			wantErr: true,
+		}, {
+			name:      "default depth",
+			depth:     0,
+			wantCount: "",
+		}, {
+			name:      "depth is forwarded",
+			depth:     100,
+			wantCount: "100",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpMock{
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader(json)),
+				},
+			}
+			api := &Kraken{
+				client: mock,
+			}
+			_, err := api.GetOrderBook("ADACAD", tt.depth)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Kraken.GetOrderBook() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Nil(t, mock.Request)
+				return
+			}
+			query := mock.Request.URL.Query()
+			_, ok := query["count"]
+			assert.Equal(t, tt.wantCount != "", ok)
+			assert.Equal(t, tt.wantCount, query.Get("count"))
+		})
+	}
+}
+
 func TestKraken_GetTrades(t *testing.T) {
 	json := []byte(`{"error":[],"result":{"ADACAD":[["0.093280","2968.26413227",1553959154.2509,"s","l","", 1]], "last": "1554221914617956627"}}`)
 	type args struct {
 		pair  string
-		since int64
+		since Cursor
 	}
 	tests := []struct {
 		name    string
@@ -637,7 +1134,7 @@ func TestKraken_GetTrades(t *testing.T) {
 			},
 			want: TradeResponse{
 				Key:  "ADACAD",
-				Last: "1554221914617956627",
+				Last: 1554221914617956627,
 				Trades: []Trade{
 					{
 						Price:     0.093280,
@@ -677,7 +1174,7 @@ func TestKraken_GetSpread(t *testing.T) {
 	json := []byte(`{"error":[],"result":{"ADACAD":[[1554224145,"0.091118","0.109331"]], "last":1554224725 }}`)
 	type args struct {
 		pair  string
-		since int64
+		since Cursor
 	}
 	tests := []struct {
 		name    string
@@ -697,6 +1194,18 @@ func TestKraken_GetSpread(t *testing.T) {
 			},
 			want:    SpreadResponse{},
 			wantErr: true,
+		}, {
+			name: "No pair",
+			err:  nil,
+			resp: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewReader(json)),
+			},
+			args: args{
+				pair: "",
+			},
+			want:    SpreadResponse{},
+			wantErr: true,
 		}, {
 			name: "Get spread",
 			err:  nil,
@@ -710,6 +1219,15 @@ func TestKraken_GetSpread(t *testing.T) {
 			},
 			want: SpreadResponse{
 				Last: 1554224725,
+				Pairs: map[string][]Spread{
+					"ADACAD": {
+						{
+							Time: 1554224145,
+							Ask:  0.109331,
+							Bid:  0.091118,
+						},
+					},
+				},
 				ADACAD: []Spread{
 					{
 						Time: 1554224145,
@@ -740,3 +1258,226 @@ func TestKraken_GetSpread(t *testing.T) {
 		})
 	}
 }
+
+func TestKraken_BackfillTrades(t *testing.T) {
+	pages := []string{
+		`{"error":[],"result":{"XXBTZUSD":[["30000.1","0.1",1688671200.1,"b","l","",1],["30000.2","0.2",1688671201.1,"s","m","",2]],"last":"1688671201100000000"}}`,
+		`{"error":["EAPI:Rate limit exceeded"]}`,
+		`{"error":[],"result":{"XXBTZUSD":[["30000.3","0.3",1688671202.1,"b","l","",3]],"last":"1688671202100000000"}}`,
+		`{"error":[],"result":{"XXBTZUSD":[],"last":"1688671202100000000"}}`,
+	}
+	mock := &httpSequenceMock{}
+	for _, page := range pages {
+		mock.Responses = append(mock.Responses, &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader([]byte(page))),
+		})
+	}
+	api := &Kraken{
+		client: mock,
+	}
+
+	var (
+		counts []int
+		times  []float64
+		trades Trades
+	)
+	cursor, err := api.BackfillTrades(context.Background(), "XXBTZUSD", 0, BackfillOptions{
+		Pause:       time.Millisecond,
+		FullHistory: true,
+		Progress: func(fetched int, lastTime float64) {
+			counts = append(counts, fetched)
+			times = append(times, lastTime)
+		},
+	}, func(page Trades) error {
+		trades = append(trades, page...)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Kraken.BackfillTrades() error = %v", err)
+		return
+	}
+	assert.Equal(t, Cursor(1688671202100000000), cursor)
+	assert.Len(t, trades, 3)
+	assert.Equal(t, []int{2, 3}, counts)
+	assert.Equal(t, []float64{1688671201.1, 1688671202.1}, times)
+	assert.Len(t, mock.Requests, 4)
+	assert.Equal(t, "1688671201100000000", mock.Requests[2].URL.Query().Get("since"))
+}
+
+func TestKraken_BackfillTrades_retryLimit(t *testing.T) {
+	mock := &httpSequenceMock{}
+	for i := 0; i < 3; i++ {
+		mock.Responses = append(mock.Responses, &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":["EAPI:Rate limit exceeded"]}`))),
+		})
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	handler := func(page Trades) error { return nil }
+
+	cursor, err := api.BackfillTrades(context.Background(), "XXBTZUSD", 1688671100000000000, BackfillOptions{MaxRetries: 1}, handler)
+	assert.True(t, isRateLimitError(err))
+	assert.Equal(t, Cursor(1688671100000000000), cursor)
+	assert.Len(t, mock.Requests, 2)
+}
+
+func TestKraken_BackfillTrades_maxPagesCountsRetries(t *testing.T) {
+	mock := &httpSequenceMock{}
+	for i := 0; i < 5; i++ {
+		mock.Responses = append(mock.Responses, &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":["EAPI:Rate limit exceeded"]}`))),
+		})
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	handler := func(page Trades) error { return nil }
+
+	cursor, err := api.BackfillTrades(context.Background(), "XXBTZUSD", 1688671100000000000, BackfillOptions{MaxPages: 2}, handler)
+	if err != nil {
+		t.Errorf("Kraken.BackfillTrades() error = %v", err)
+		return
+	}
+	assert.Equal(t, Cursor(1688671100000000000), cursor)
+	assert.Len(t, mock.Requests, 2)
+}
+
+func Test_backfillBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backfillBackoff(0, 0))
+	assert.Equal(t, 4*time.Second, backfillBackoff(0, 2))
+	assert.Equal(t, 6*time.Second, backfillBackoff(3*time.Second, 1))
+	assert.Equal(t, time.Minute, backfillBackoff(time.Second, 10))
+	assert.Equal(t, time.Minute, backfillBackoff(time.Second, 100))
+}
+
+func TestKraken_BackfillTrades_until(t *testing.T) {
+	mock := &httpSequenceMock{
+		Responses: []*http.Response{{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":[],"result":{"XXBTZUSD":[["30000.1","0.1",1688671200.1,"b","l","",1],["30000.2","0.2",1688671201.1,"s","m","",2]],"last":"1688671201100000000"}}`))),
+		}},
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	until := time.Unix(1688671201, 0)
+	var trades Trades
+	cursor, err := api.BackfillTrades(context.Background(), "XXBTZUSD", 1688671100000000000, BackfillOptions{Until: until}, func(page Trades) error {
+		trades = append(trades, page...)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Kraken.BackfillTrades() error = %v", err)
+		return
+	}
+	assert.Len(t, trades, 1)
+	assert.Equal(t, TradesCursor(until), cursor)
+}
+
+func TestKraken_BackfillTrades_guards(t *testing.T) {
+	pages := []string{
+		`{"error":[],"result":{"XXBTZUSD":[["30000.1","0.1",1688671200.1,"b","l","",1]],"last":"1688671200100000000"}}`,
+		`{"error":[],"result":{"XXBTZUSD":[["30000.2","0.2",1688671201.1,"s","m","",2]],"last":"1688671201100000000"}}`,
+		`{"error":[],"result":{"XXBTZUSD":[["30000.3","0.3",1688671202.1,"b","l","",3]],"last":"1688671202100000000"}}`,
+	}
+	mock := &httpSequenceMock{}
+	for _, page := range pages {
+		mock.Responses = append(mock.Responses, &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader([]byte(page))),
+		})
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	handler := func(page Trades) error { return nil }
+
+	_, err := api.BackfillTrades(context.Background(), "XXBTZUSD", 0, BackfillOptions{}, handler)
+	assert.NotNil(t, err)
+	assert.Len(t, mock.Requests, 0)
+
+	cursor, err := api.BackfillTrades(context.Background(), "XXBTZUSD", 0, BackfillOptions{FullHistory: true, MaxPages: 2}, handler)
+	if err != nil {
+		t.Errorf("Kraken.BackfillTrades() error = %v", err)
+		return
+	}
+	assert.Len(t, mock.Requests, 2)
+	assert.Equal(t, Cursor(1688671201100000000), cursor)
+}
+
+func TestKraken_GetSpreadDecimal(t *testing.T) {
+	json := []byte(`{"error":[],"result":{"XDGXBT":[[1554224145,"0.000091180","0.000091190"]], "last":1554224725 }}`)
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(json)),
+		},
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	got, last, err := api.GetSpreadDecimal("XDGXBT", 0)
+	if err != nil {
+		t.Errorf("Kraken.GetSpreadDecimal() error = %v", err)
+		return
+	}
+	assert.Equal(t, Cursor(1554224725), last)
+	if assert.Len(t, got["XDGXBT"], 1) {
+		spread := got["XDGXBT"][0]
+		assert.Equal(t, int64(1554224145), spread.Time)
+		assert.Equal(t, "0.000091180", spread.Bid.String())
+		assert.Equal(t, "0.000091190", spread.Ask.String())
+	}
+}
+
+func TestKraken_GetTradesFromTime(t *testing.T) {
+	json := []byte(`{"error":[],"result":{"ADACAD":[["0.093280","2968.26413227",1553959154.2509,"s","l","", 1]], "last": "1554221914617956627"}}`)
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(json)),
+		},
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	from := time.Date(2019, time.April, 2, 15, 15, 8, 500, time.UTC)
+	got, err := api.GetTradesFromTime("ADACAD", from, 10)
+	if err != nil {
+		t.Errorf("Kraken.GetTradesFromTime() error = %v", err)
+		return
+	}
+	assert.Equal(t, "1554218108000000500", mock.Request.URL.Query().Get("since"))
+	assert.Equal(t, "10", mock.Request.URL.Query().Get("count"))
+	assert.Equal(t, Cursor(1554221914617956627), got.Last)
+}
+
+func TestKraken_GetTradesMulti(t *testing.T) {
+	json := []byte(`{"error":[],"result":{"ADACAD":[["0.093280","2968.26413227",1553959154.2509,"s","l","",1]],"XXBTZUSD":[["7000.6","0.2",1553959155.1,"b","m","",2],["7000.7","0.1",1553959156.1,"b","l","",3]],"last":"1554221914617956627"}}`)
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(json)),
+		},
+	}
+	api := &Kraken{
+		client: mock,
+	}
+	got, last, err := api.GetTradesMulti([]string{"ADACAD", "XXBTZUSD"}, 0)
+	if err != nil {
+		t.Errorf("Kraken.GetTradesMulti() error = %v", err)
+		return
+	}
+	assert.Equal(t, "ADACAD,XXBTZUSD", mock.Request.URL.Query().Get("pair"))
+	assert.Equal(t, Cursor(1554221914617956627), last)
+	assert.Len(t, got, 2)
+	assert.Len(t, got["ADACAD"], 1)
+	if assert.Len(t, got["XXBTZUSD"], 2) {
+		assert.Equal(t, 7000.7, got["XXBTZUSD"][1].Price)
+		assert.Equal(t, 3.0, got["XXBTZUSD"][1].TradeID)
+	}
+}
@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Option - option function for `NewWithOptions`
+type Option func(*Kraken)
+
+// WithBaseURL - add custom API endpoint, e.g. proxy or mock server. Default: APIUrl.
+func WithBaseURL(url string) Option {
+	return func(api *Kraken) {
+		api.baseURL = url
+	}
+}
+
+// WithAPIVersion - add custom API version. Default: APIVersion.
+func WithAPIVersion(version string) Option {
+	return func(api *Kraken) {
+		api.version = version
+	}
+}
+
+// WithHTTPClient - add custom HTTP client. Default: http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(api *Kraken) {
+		api.client = client
+	}
+}
+
+// WithTimeout - add custom timeout of single request. Default: 30s.
+func WithTimeout(timeout time.Duration) Option {
+	return func(api *Kraken) {
+		api.timeout = timeout
+	}
+}
+
+// WithBatchPause - add custom pause between batches of request split by ids count limit, e.g. QueryOrders. Default: 1s.
+func WithBatchPause(pause time.Duration) Option {
+	return func(api *Kraken) {
+		api.pause = pause
+	}
+}
+
+// WithLogger - add custom logger. Default: standard logrus logger.
+func WithLogger(logger log.FieldLogger) Option {
+	return func(api *Kraken) {
+		api.logger = logger
+	}
+}
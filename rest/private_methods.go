@@ -1,11 +1,14 @@
 package rest
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ericlagergren/decimal"
 )
@@ -19,6 +22,182 @@ func (api *Kraken) GetAccountBalances() (map[string]*decimal.Big, error) {
 	return response, nil
 }
 
+// GetExtendedBalances - returns account balances including amounts on hold
+func (api *Kraken) GetExtendedBalances() (map[string]ExtendedBalance, error) {
+	response := make(map[string]ExtendedBalance)
+	if err := api.request("BalanceEx", true, nil, &response, "POST"); err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// AvailableBalance - returns balance of `asset` which could be committed to a new order, i.e. balance minus amount held by open orders.
+// Zero is returned if account has no such asset.
+func (api *Kraken) AvailableBalance(asset string) (float64, error) {
+	balances, err := api.GetExtendedBalances()
+	if err != nil {
+		return 0, err
+	}
+	balance, ok := balances[asset]
+	if !ok || balance.Balance == nil {
+		return 0, nil
+	}
+	available := newDecimal().Copy(balance.Balance)
+	if balance.HoldTrade != nil {
+		available.Sub(available, balance.HoldTrade)
+	}
+	value, _ := available.Float64()
+	return value, nil
+}
+
+// EarnStrategies - returns all earn strategies available for `asset`. Empty `asset` means all assets.
+func (api *Kraken) EarnStrategies(asset string) ([]EarnStrategy, error) {
+	strategies := make([]EarnStrategy, 0)
+	cursor := ""
+	for {
+		data := url.Values{}
+		if asset != "" {
+			data.Set("asset", asset)
+		}
+		if cursor != "" {
+			data.Set("cursor", cursor)
+		}
+		response := EarnStrategiesResponse{}
+		if err := api.request("Earn/Strategies", true, data, &response, "POST"); err != nil {
+			return nil, err
+		}
+		strategies = append(strategies, response.Items...)
+		if response.NextCursor == "" || response.NextCursor == cursor {
+			return strategies, nil
+		}
+		cursor = response.NextCursor
+	}
+}
+
+// EarnAllocate - allocates `amount` of asset to earn strategy. Allocation is asynchronous, check its status with EarnAllocateStatus.
+func (api *Kraken) EarnAllocate(strategyID string, amount float64) (bool, error) {
+	return api.earnAllocation("Earn/Allocate", strategyID, amount)
+}
+
+// EarnDeallocate - deallocates `amount` of asset from earn strategy. Deallocation is asynchronous, check its status with EarnDeallocateStatus.
+func (api *Kraken) EarnDeallocate(strategyID string, amount float64) (bool, error) {
+	return api.earnAllocation("Earn/Deallocate", strategyID, amount)
+}
+
+func (api *Kraken) earnAllocation(method string, strategyID string, amount float64) (bool, error) {
+	if strategyID == "" {
+		return false, errors.New("`strategyID` is required")
+	}
+	if amount <= 0 {
+		return false, errors.New("`amount` must be positive")
+	}
+	data := url.Values{
+		"strategy_id": {strategyID},
+		"amount":      {strconv.FormatFloat(amount, 'f', -1, 64)},
+	}
+	var response bool
+	if err := api.request(method, true, data, &response, "POST"); err != nil {
+		return false, err
+	}
+	return response, nil
+}
+
+// EarnAllocateStatus - returns status of the last allocation to earn strategy. `Pending` is false once allocation is completed.
+func (api *Kraken) EarnAllocateStatus(strategyID string) (EarnOperationStatus, error) {
+	return api.earnOperationStatus("Earn/AllocateStatus", strategyID)
+}
+
+// EarnDeallocateStatus - returns status of the last deallocation from earn strategy. `Pending` is false once deallocation is completed.
+func (api *Kraken) EarnDeallocateStatus(strategyID string) (EarnOperationStatus, error) {
+	return api.earnOperationStatus("Earn/DeallocateStatus", strategyID)
+}
+
+func (api *Kraken) earnOperationStatus(method string, strategyID string) (EarnOperationStatus, error) {
+	response := EarnOperationStatus{}
+	if strategyID == "" {
+		return response, errors.New("`strategyID` is required")
+	}
+	data := url.Values{
+		"strategy_id": {strategyID},
+	}
+	if err := api.request(method, true, data, &response, "POST"); err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// EarnAllocations - returns allocations to earn strategies
+func (api *Kraken) EarnAllocations() ([]EarnAllocation, error) {
+	response := EarnAllocationsResponse{}
+	if err := api.request("Earn/Allocations", true, nil, &response, "POST"); err != nil {
+		return nil, err
+	}
+	return response.Items, nil
+}
+
+// Suffixes of staked and earn assets in extended balance
+var (
+	stakedAssetSuffixes = []string{".S", ".M"}
+	earnAssetSuffixes   = []string{".B", ".F"}
+)
+
+// ConsolidatedBalance - returns per asset view of spot balances, staked and earn amounts. The amounts are taken from `BalanceEx`, so they include rewards.
+// Staked amount is balance of asset with staking suffix (`.S` or `.M`), earn amount is balance of asset with earn suffix (`.B` or `.F`).
+// The suffix is trimmed. Balances with other suffixes (e.g. `.P` parachain or `.HOLD`) are not spendable or earning, so they are ignored.
+func (api *Kraken) ConsolidatedBalance() (map[string]ConsolidatedAsset, error) {
+	balances, err := api.GetExtendedBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	response := make(map[string]ConsolidatedAsset)
+	get := func(asset string) ConsolidatedAsset {
+		item, ok := response[asset]
+		if !ok {
+			item = ConsolidatedAsset{
+				Spot:   newDecimal(),
+				Staked: newDecimal(),
+				Earn:   newDecimal(),
+				Total:  newDecimal(),
+			}
+		}
+		return item
+	}
+	cutSuffix := func(asset string, suffixes []string) (string, bool) {
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(asset, suffix) {
+				return strings.TrimSuffix(asset, suffix), true
+			}
+		}
+		return asset, false
+	}
+
+	for asset, balance := range balances {
+		if balance.Balance == nil {
+			continue
+		}
+		if !strings.Contains(asset, ".") {
+			item := get(asset)
+			item.Spot.Add(item.Spot, balance.Balance)
+			response[asset] = item
+		} else if base, ok := cutSuffix(asset, stakedAssetSuffixes); ok {
+			item := get(base)
+			item.Staked.Add(item.Staked, balance.Balance)
+			response[base] = item
+		} else if base, ok := cutSuffix(asset, earnAssetSuffixes); ok {
+			item := get(base)
+			item.Earn.Add(item.Earn, balance.Balance)
+			response[base] = item
+		}
+	}
+
+	for _, item := range response {
+		item.Total.Add(item.Spot, item.Staked)
+		item.Total.Add(item.Total, item.Earn)
+	}
+	return response, nil
+}
+
 // GetTradeBalance - returns tradable balances info
 func (api *Kraken) GetTradeBalance(baseAsset string) (TradeBalanceResponse, error) {
 	data := url.Values{}
@@ -26,11 +205,7 @@ func (api *Kraken) GetTradeBalance(baseAsset string) (TradeBalanceResponse, erro
 		data.Set("asset", baseAsset)
 	}
 
-	response := TradeBalanceResponse{}
-	if err := api.request("TradeBalance", true, data, &response, "POST"); err != nil {
-		return response, err
-	}
-	return response, nil
+	return do[TradeBalanceResponse](api, "TradeBalance", true, data, "POST")
 }
 
 // GetOpenOrders - returns account open order
@@ -43,37 +218,41 @@ func (api *Kraken) GetOpenOrders(needTrades bool, userRef string) (OpenOrdersRes
 		data.Set("userref", userRef)
 	}
 
-	response := OpenOrdersResponse{}
-	if err := api.request("OpenOrders", true, data, &response, "POST"); err != nil {
-		return response, err
-	}
-	return response, nil
+	return do[OpenOrdersResponse](api, "OpenOrders", true, data, "POST")
 }
 
-// GetClosedOrders - returns account closed order
-func (api *Kraken) GetClosedOrders(needTrades bool, userRef string, start int64, end int64) (ClosedOrdersResponse, error) {
+// GetClosedOrders - returns account closed order.
+// `start` and `end` - either unix timestamps or order txids. Omitted if empty.
+// `withoutCount` - skips counting of all closed orders to respond faster. `Count` of response is zero in this case.
+func (api *Kraken) GetClosedOrders(needTrades bool, userRef string, start string, end string, withoutCount bool) (ClosedOrdersResponse, error) {
 	data := url.Values{}
 	if needTrades {
 		data.Set("trades", "true")
 	}
+	if withoutCount {
+		data.Set("without_count", "true")
+	}
 	if userRef != "" {
 		data.Set("userref", userRef)
 	}
-	if start != 0 {
-		data.Set("start", strconv.FormatInt(start, 10))
+	if start != "" {
+		data.Set("start", start)
 	}
-	if end != 0 {
-		data.Set("end", strconv.FormatInt(end, 10))
+	if end != "" {
+		data.Set("end", end)
 	}
 
 	response := ClosedOrdersResponse{}
 	if err := api.request("ClosedOrders", true, data, &response, "POST"); err != nil {
 		return response, err
 	}
+	if withoutCount {
+		response.Count = 0
+	}
 	return response, nil
 }
 
-// QueryOrders - returns account's order by IDs
+// QueryOrders - returns account's order by IDs. More than 50 ids are requested in several batches.
 func (api *Kraken) QueryOrders(needTrades bool, userRef string, txIDs ...string) (map[string]OrderInfo, error) {
 	data := url.Values{}
 	if needTrades {
@@ -83,24 +262,65 @@ func (api *Kraken) QueryOrders(needTrades bool, userRef string, txIDs ...string)
 		data.Set("userref", userRef)
 	}
 
-	switch {
-	case len(txIDs) > 50:
-		return nil, errors.New("maximum count of requested orders is 50")
-	case len(txIDs) == 0:
+	if len(txIDs) == 0 {
 		return nil, errors.New("txIDs is required")
-	default:
-		data.Set("txid", strings.Join(txIDs, ","))
 	}
+	return requestBatched[OrderInfo](api, "QueryOrders", txIDs, maxQueryOrders, data)
+}
 
-	response := make(map[string]OrderInfo)
-	if err := api.request("QueryOrders", true, data, &response, "POST"); err != nil {
-		return nil, err
+// QueryOrdersDecimal - returns volumes and amounts of account's orders by IDs with full precision. See QueryOrders for details.
+func (api *Kraken) QueryOrdersDecimal(userRef string, txIDs ...string) (map[string]OrderInfoDecimal, error) {
+	data := url.Values{}
+	if userRef != "" {
+		data.Set("userref", userRef)
+	}
+
+	if len(txIDs) == 0 {
+		return nil, errors.New("txIDs is required")
+	}
+	return requestBatched[OrderInfoDecimal](api, "QueryOrders", txIDs, maxQueryOrders, data)
+}
+
+// Maximum counts of ids accepted by Kraken in one request
+const (
+	maxQueryOrders = 50
+	maxQueryTrades = 20
+)
+
+// requestBatched - requests private `method` with `txIDs` split into batches of `batchSize` ids passed as `txid` parameter along with `data`.
+// Batches are sent one by one with pause between them (see WithBatchPause) and results are merged into one map. The first failed batch stops request.
+func requestBatched[T any](api *Kraken, method string, txIDs []string, batchSize int, data url.Values) (map[string]T, error) {
+	response := make(map[string]T)
+	for start := 0; start < len(txIDs); start += batchSize {
+		if start > 0 {
+			if err := sleepContext(api.parentContext(), api.batchPause()); err != nil {
+				return nil, err
+			}
+		}
+		end := start + batchSize
+		if end > len(txIDs) {
+			end = len(txIDs)
+		}
+		values := url.Values{}
+		for k, v := range data {
+			values[k] = v
+		}
+		values.Set("txid", strings.Join(txIDs[start:end], ","))
+
+		batch := make(map[string]T)
+		if err := api.request(method, true, values, &batch, "POST"); err != nil {
+			return nil, err
+		}
+		for k, v := range batch {
+			response[k] = v
+		}
 	}
 	return response, nil
 }
 
-// GetTradesHistory - returns account's trade history
-func (api *Kraken) GetTradesHistory(tradeType string, needTrades bool, start int64, end int64) (TradesHistoryResponse, error) {
+// GetTradesHistory - returns account's trade history.
+// `start` and `end` - either unix timestamps or trade txids. Omitted if empty.
+func (api *Kraken) GetTradesHistory(tradeType string, needTrades bool, start string, end string) (TradesHistoryResponse, error) {
 	data := url.Values{
 		"type": {"all"},
 	}
@@ -110,17 +330,13 @@ func (api *Kraken) GetTradesHistory(tradeType string, needTrades bool, start int
 	if tradeType != "" {
 		data.Set("type", tradeType)
 	}
-	if start != 0 {
-		data.Set("start", strconv.FormatInt(start, 10))
-	}
-	if end != 0 {
-		data.Set("end", strconv.FormatInt(end, 10))
+	if start != "" {
+		data.Set("start", start)
 	}
-	response := TradesHistoryResponse{}
-	if err := api.request("TradesHistory", true, data, &response, "POST"); err != nil {
-		return response, err
+	if end != "" {
+		data.Set("end", end)
 	}
-	return response, nil
+	return do[TradesHistoryResponse](api, "TradesHistory", true, data, "POST")
 }
 
 // GetDepositMethods - returns deposit methods
@@ -156,6 +372,29 @@ func (api *Kraken) GetDepositStatus(method string, assets ...string) ([]DepositS
 	return response, nil
 }
 
+// GetDepositStatusPaged - returns one page of deposit statuses and cursor of the next page.
+// Pass empty `cursor` to get the first page. Returned cursor is empty if there are no more pages.
+func (api *Kraken) GetDepositStatusPaged(asset string, method string, cursor string) ([]DepositStatuses, string, error) {
+	data := url.Values{
+		"cursor": {"true"},
+	}
+	if len(asset) > 0 {
+		data.Add("asset", asset)
+	}
+	if len(method) > 0 {
+		data.Add("method", method)
+	}
+	if len(cursor) > 0 {
+		data.Set("cursor", cursor)
+	}
+
+	response := DepositStatusPage{}
+	if err := api.request("DepositStatus", true, data, &response, "POST"); err != nil {
+		return nil, "", err
+	}
+	return response.Deposits, response.NextCursor, nil
+}
+
 // WithdrawInfo - Retrieve fee information about potential withdrawals for a particular asset, key and amount.
 func (api *Kraken) WithdrawInfo(asset string, key string, amount float64) (response WithdrawInfo, err error) {
 	data := url.Values{
@@ -203,7 +442,30 @@ func (api *Kraken) GetWithdrawStatus(asset string, method string) ([]WithdrawSta
 	return response, nil
 }
 
-// QueryTrades - returns trades by IDs
+// GetWithdrawStatusPaged - returns one page of withdrawal statuses and cursor of the next page.
+// Pass empty `cursor` to get the first page. Returned cursor is empty if there are no more pages.
+func (api *Kraken) GetWithdrawStatusPaged(asset string, method string, cursor string) ([]WithdrawStatus, string, error) {
+	data := url.Values{
+		"cursor": {"true"},
+	}
+	if len(asset) > 0 {
+		data.Add("asset", asset)
+	}
+	if len(method) > 0 {
+		data.Add("method", method)
+	}
+	if len(cursor) > 0 {
+		data.Set("cursor", cursor)
+	}
+
+	response := WithdrawStatusPage{}
+	if err := api.request("WithdrawStatus", true, data, &response, "POST"); err != nil {
+		return nil, "", err
+	}
+	return response.Withdrawals, response.NextCursor, nil
+}
+
+// QueryTrades - returns trades by IDs. More than 20 ids are requested in several batches.
 func (api *Kraken) QueryTrades(trades bool, txIDs ...string) (map[string]PrivateTrade, error) {
 	data := url.Values{}
 	if trades {
@@ -212,13 +474,7 @@ func (api *Kraken) QueryTrades(trades bool, txIDs ...string) (map[string]Private
 	if len(txIDs) == 0 {
 		return nil, errors.New("txIDs is required")
 	}
-	data.Set("txid", strings.Join(txIDs, ","))
-
-	response := make(map[string]PrivateTrade)
-	if err := api.request("QueryTrades", true, data, &response, "POST"); err != nil {
-		return nil, err
-	}
-	return response, nil
+	return requestBatched[PrivateTrade](api, "QueryTrades", txIDs, maxQueryTrades, data)
 }
 
 // GetOpenPositions - returns list of open positions
@@ -239,12 +495,20 @@ func (api *Kraken) GetOpenPositions(docalcs bool, txIDs ...string) (map[string]P
 	return response, nil
 }
 
-// GetLedgersInfo - returns ledgers info
+// GetLedgersInfo - returns ledgers info of type `ledgerType` (one of LedgerType* constants). Kraken's default if empty string passed.
 func (api *Kraken) GetLedgersInfo(ledgerType string, start int64, end int64, assets ...string) (LedgerInfoResponse, error) {
+	return api.GetLedgersInfoByClass("", ledgerType, start, end, assets...)
+}
+
+// GetLedgersInfoByClass - returns ledgers info of assets of asset class `aclass` (one of AssetClass* constants). Kraken's default if empty string passed.
+func (api *Kraken) GetLedgersInfoByClass(aclass string, ledgerType string, start int64, end int64, assets ...string) (LedgerInfoResponse, error) {
 	response := LedgerInfoResponse{}
 	data := url.Values{}
+	if aclass != "" {
+		data.Set("aclass", aclass)
+	}
 	if ledgerType != "" {
-		data.Set("type", LedgerTypeAll)
+		data.Set("type", ledgerType)
 	}
 	if start != 0 {
 		data.Set("start", strconv.FormatInt(start, 10))
@@ -298,14 +562,7 @@ func (api *Kraken) GetTradeVolume(needFeeInfo bool, pairs ...string) (TradeVolum
 	return response, nil
 }
 
-// AddOrder - method sends order to exchange
-func (api *Kraken) AddOrder(pair string, side string, orderType string, volume float64, args map[string]interface{}) (response AddOrderResponse, err error) {
-	data := url.Values{
-		"pair":      {pair},
-		"volume":    {strconv.FormatFloat(volume, 'f', 8, 64)},
-		"type":      {side},
-		"ordertype": {orderType},
-	}
+func setArgs(data url.Values, args map[string]interface{}) {
 	for key, value := range args {
 		switch v := value.(type) {
 		case string:
@@ -316,35 +573,272 @@ func (api *Kraken) AddOrder(pair string, side string, orderType string, volume f
 			data.Set(key, strconv.FormatFloat(v, 'f', 8, 64))
 		case bool:
 			data.Set(key, strconv.FormatBool(v))
+		case time.Time:
+			if !v.IsZero() {
+				data.Set(key, v.UTC().Format(time.RFC3339))
+			}
+		case []OrderFlag:
+			flags := make([]string, len(v))
+			for i := range v {
+				flags[i] = string(v[i])
+			}
+			data.Set(key, strings.Join(flags, ","))
 		default:
 			log.Printf("[WARNING] Unknown value type %v for key %s", value, key)
 		}
 	}
+}
+
+func validateClientOrderID(value interface{}, args map[string]interface{}) error {
+	if id, ok := value.(string); !ok || id == "" {
+		return fmt.Errorf("`cl_ord_id` must be non-empty string, got %v", value)
+	}
+	if _, ok := args[ArgUserRef]; ok {
+		return errors.New("`cl_ord_id` can't be used together with `userref`")
+	}
+	return nil
+}
+
+func validateDisplayVolume(orderType string, volume float64, value interface{}) error {
+	if orderType != OTLimit {
+		return errors.New("`displayvol` is applicable only to limit orders")
+	}
+	var displayVolume float64
+	switch v := value.(type) {
+	case float64:
+		displayVolume = v
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		displayVolume = f
+	default:
+		return errors.New("`displayvol` must be a float64 or a string")
+	}
+	if displayVolume <= 0 || displayVolume >= volume {
+		return errors.New("`displayvol` must be positive and less than `volume`")
+	}
+	return nil
+}
+
+func validateOrderFlags(value interface{}) error {
+	var flags []OrderFlag
+	switch v := value.(type) {
+	case []OrderFlag:
+		flags = v
+	case string:
+		for _, flag := range strings.Split(v, ",") {
+			flags = append(flags, OrderFlag(flag))
+		}
+	default:
+		return errors.New("`oflags` must be a []OrderFlag or a comma separated string")
+	}
+	for _, flag := range flags {
+		switch flag {
+		case OFlagVolumeInQuote, OFlagFeeInBase, OFlagFeeInQuote, OFlagNoMPP, OFlagPostOnly:
+		default:
+			return fmt.Errorf("invalid order flag %q", flag)
+		}
+	}
+	return nil
+}
+
+func validateSide(side string) error {
+	switch side {
+	case SideBuy, SideSell:
+		return nil
+	}
+	return fmt.Errorf("invalid order side %q", side)
+}
+
+func validateOrderType(orderType string) error {
+	switch orderType {
+	case OrderTypeMarket, OrderTypeLimit, OrderTypeStopLoss, OrderTypeTakeProfit, OrderTypeStopLossProfit,
+		OrderTypeStopLossProfitLimit, OrderTypeStopLossLimit, OrderTypeTakeProfitLimit, OrderTypeTrailingStop,
+		OrderTypeTrailingStopLimit, OrderTypeStopLossAndLimit, OrderTypeSettlePosition:
+		return nil
+	}
+	return fmt.Errorf("invalid order type %q", orderType)
+}
+
+func validateSTPType(value interface{}) error {
+	switch value {
+	case STPCancelNewest, STPCancelOldest, STPCancelBoth:
+		return nil
+	default:
+		return fmt.Errorf("invalid `stptype` %v", value)
+	}
+}
+
+// AddOrder - method sends order to exchange.
+// Pass `time.Time` value with key `deadline` in `args` to reject the order if it can not be processed before that time. Zero time is omitted.
+// Pass value with key `displayvol` in `args` to place an iceberg order. It is applicable only to limit orders and must be less than `volume`.
+// Pass one of STP* constants with key `stptype` in `args` to choose self trade prevention behaviour.
+// Pass `[]OrderFlag` or comma separated string with key `oflags` in `args` to set order flags. Unknown flags are rejected.
+// Pass string with key `cl_ord_id` in `args` to set client order id (e.g. UUID). It can't be combined with `userref`.
+// `side` must be one of Side* constants and `orderType` one of OrderType* constants.
+func (api *Kraken) AddOrder(pair string, side string, orderType string, volume float64, args map[string]interface{}) (response AddOrderResponse, err error) {
+	if err = validateSide(side); err != nil {
+		return
+	}
+	if err = validateOrderType(orderType); err != nil {
+		return
+	}
+	if flags, ok := args[ArgOrderFlags]; ok {
+		if err = validateOrderFlags(flags); err != nil {
+			return
+		}
+	}
+	if stpType, ok := args[ArgSTPType]; ok {
+		if err = validateSTPType(stpType); err != nil {
+			return
+		}
+	}
+	if displayVolume, ok := args[ArgDisplayVolume]; ok {
+		if err = validateDisplayVolume(orderType, volume, displayVolume); err != nil {
+			return
+		}
+	}
+	if clOrdID, ok := args[ArgClientOrderID]; ok {
+		if err = validateClientOrderID(clOrdID, args); err != nil {
+			return
+		}
+	}
+	data := url.Values{
+		"pair":      {pair},
+		"volume":    {strconv.FormatFloat(volume, 'f', 8, 64)},
+		"type":      {side},
+		"ordertype": {orderType},
+	}
+	setArgs(data, args)
 
 	err = api.request("AddOrder", true, data, &response, "POST")
 	return
 }
 
+// AddOrderIdempotent - sends order with client order id `clOrdID` unless open or closed order with this id already exists.
+// Existing order is returned instead of submitting it again, so request could be safely retried after timeout.
+// Other arguments are the same as in AddOrder.
+func (api *Kraken) AddOrderIdempotent(clOrdID string, pair string, side string, orderType string, volume float64, args map[string]interface{}) (AddOrderResponse, error) {
+	if clOrdID == "" {
+		return AddOrderResponse{}, errors.New("client order id is required")
+	}
+	txID, order, found, err := api.findOrderByClientID(clOrdID)
+	if err != nil {
+		return AddOrderResponse{}, err
+	}
+	if found {
+		return AddOrderResponse{
+			Description:    order.Description,
+			TransactionIds: []string{txID},
+		}, nil
+	}
+
+	orderArgs := make(map[string]interface{}, len(args)+1)
+	for key, value := range args {
+		orderArgs[key] = value
+	}
+	orderArgs[ArgClientOrderID] = clOrdID
+	return api.AddOrder(pair, side, orderType, volume, orderArgs)
+}
+
+// safeAddOrderAttempts - maximum count of order submissions made by SafeAddOrder
+const safeAddOrderAttempts = 3
+
+// Range of order deadline window accepted by Kraken
+const (
+	minOrderDeadline = 2 * time.Second
+	maxOrderDeadline = 60 * time.Second
+)
+
+// SafeAddOrder - sends order which is safe to retry after network timeout. Order is validated first (`validate=true`), then it's submitted
+// with client order id `clOrdID` and deadline `window` from now (Kraken accepts 2-60 seconds). If submission fails with temporary error (see IsTemporary),
+// it waits until deadline, so Kraken can't process the order anymore, and looks for it by `clOrdID` in open and closed orders.
+// Found order is returned, otherwise order is submitted again. Other arguments are the same as in AddOrder.
+func (api *Kraken) SafeAddOrder(ctx context.Context, clOrdID string, pair string, side string, orderType string, volume float64, args map[string]interface{}, window time.Duration) (AddOrderResponse, error) {
+	if clOrdID == "" {
+		return AddOrderResponse{}, errors.New("client order id is required")
+	}
+	if window < minOrderDeadline || window > maxOrderDeadline {
+		return AddOrderResponse{}, fmt.Errorf("deadline window %s is out of range %s-%s", window, minOrderDeadline, maxOrderDeadline)
+	}
+	client := api.WithContext(ctx)
+
+	orderArgs := make(map[string]interface{}, len(args)+2)
+	for key, value := range args {
+		orderArgs[key] = value
+	}
+	orderArgs[ArgClientOrderID] = clOrdID
+
+	orderArgs[ArgValidate] = true
+	if _, err := client.AddOrder(pair, side, orderType, volume, orderArgs); err != nil {
+		return AddOrderResponse{}, fmt.Errorf("order validation failed: %w", err)
+	}
+	delete(orderArgs, ArgValidate)
+
+	var lastErr error
+	for attempt := 0; attempt < safeAddOrderAttempts; attempt++ {
+		deadline := time.Now().Add(window)
+		orderArgs[ArgDeadline] = deadline
+		response, err := client.AddOrder(pair, side, orderType, volume, orderArgs)
+		if err == nil || !IsTemporary(err) {
+			return response, err
+		}
+		lastErr = err
+
+		if err := sleepContext(ctx, time.Until(deadline)); err != nil {
+			return AddOrderResponse{}, err
+		}
+		txID, order, found, err := client.findOrderByClientID(clOrdID)
+		if err != nil {
+			return AddOrderResponse{}, err
+		}
+		if found {
+			return AddOrderResponse{
+				Description:    order.Description,
+				TransactionIds: []string{txID},
+			}, nil
+		}
+	}
+	return AddOrderResponse{}, fmt.Errorf("order was not placed after %d attempts: %w", safeAddOrderAttempts, lastErr)
+}
+
+// findOrderByClientID - looks for open or closed order with client order id `clOrdID`
+func (api *Kraken) findOrderByClientID(clOrdID string) (string, OrderInfo, bool, error) {
+	data := url.Values{
+		ArgClientOrderID: {clOrdID},
+	}
+	open := OpenOrdersResponse{}
+	if err := api.request("OpenOrders", true, data, &open, "POST"); err != nil {
+		return "", OrderInfo{}, false, err
+	}
+	for txID, order := range open.Orders {
+		if order.ClientOrderID == clOrdID {
+			return txID, order, true, nil
+		}
+	}
+
+	data.Set("without_count", "true")
+	closed := ClosedOrdersResponse{}
+	if err := api.request("ClosedOrders", true, data, &closed, "POST"); err != nil {
+		return "", OrderInfo{}, false, err
+	}
+	for txID, order := range closed.Orders {
+		if order.ClientOrderID == clOrdID {
+			return txID, order, true, nil
+		}
+	}
+	return "", OrderInfo{}, false, nil
+}
+
 // EditOrder - method edits an existing order in the exchange
 func (api *Kraken) EditOrder(orderId string, pair string, args map[string]interface{}) (response EditOrderResponse, err error) {
 	data := url.Values{
 		"txid": {orderId},
 		"pair": {pair},
 	}
-	for key, value := range args {
-		switch v := value.(type) {
-		case string:
-			data.Set(key, v)
-		case int64:
-			data.Set(key, strconv.FormatInt(v, 10))
-		case float64:
-			data.Set(key, strconv.FormatFloat(v, 'f', 8, 64))
-		case bool:
-			data.Set(key, strconv.FormatBool(v))
-		default:
-			log.Printf("[WARNING] Unknown value type %v for key %s", value, key)
-		}
-	}
+	setArgs(data, args)
 
 	err = api.request("EditOrder", true, data, &response, "POST")
 	return
@@ -359,8 +853,147 @@ func (api *Kraken) Cancel(orderID string) (response CancelResponse, err error) {
 	return
 }
 
+// CancelByUserRef - cancels all open orders with user reference `userref`
+func (api *Kraken) CancelByUserRef(userref int32) (response CancelResponse, err error) {
+	data := url.Values{
+		"txid": {strconv.FormatInt(int64(userref), 10)},
+	}
+	err = api.request("CancelOrder", true, data, &response, "POST")
+	return
+}
+
 // GetWebSocketsToken - WebSockets authentication
 func (api *Kraken) GetWebSocketsToken() (response GetWebSocketTokenResponse, err error) {
 	err = api.request("GetWebSocketsToken", true, nil, &response, "POST")
 	return
 }
+
+// feeTierTTL - time while fee tier used by EstimateFee is cached. Tier depends on 30-day trade volume, so it changes rarely.
+const feeTierTTL = time.Hour
+
+// EstimateFee - returns fee in quote currency for hypothetical order of `volume` at `price`.
+// User's fee tier from TradeVolume is used if API key is set, otherwise pair's default fee schedule. Fee tier is cached for an hour.
+func (api *Kraken) EstimateFee(pair string, volume, price float64, maker bool) (float64, error) {
+	tier, err := api.feeTier(pair)
+	if err != nil {
+		return 0, err
+	}
+	fee := tier.taker
+	if maker {
+		fee = tier.maker
+	}
+	return volume * price * fee / 100, nil
+}
+
+func (api *Kraken) feeTier(pair string) (feeTier, error) {
+	cache := api.fees
+	if cache == nil {
+		return api.fetchFeeTier(pair)
+	}
+
+	cache.mx.Lock()
+	defer cache.mx.Unlock()
+
+	now := time.Now
+	if cache.now != nil {
+		now = cache.now
+	}
+	if tier, ok := cache.tiers[pair]; ok && now().Before(tier.expiresAt) {
+		return tier, nil
+	}
+
+	tier, err := api.fetchFeeTier(pair)
+	if err != nil {
+		return tier, err
+	}
+	tier.expiresAt = now().Add(feeTierTTL)
+	if cache.tiers == nil {
+		cache.tiers = make(map[string]feeTier)
+	}
+	cache.tiers[pair] = tier
+	return tier, nil
+}
+
+func (api *Kraken) fetchFeeTier(pair string) (feeTier, error) {
+	if api.key == "" {
+		pairs, err := api.AssetPairsInfo(AssetPairsInfoFees, pair)
+		if err != nil {
+			return feeTier{}, err
+		}
+		_, info, ok := FirstResult(pairs)
+		if !ok || len(info.Fees) == 0 || len(info.Fees[0]) < 2 {
+			return feeTier{}, fmt.Errorf("no fee schedule of pair %s", pair)
+		}
+		tier := feeTier{taker: info.Fees[0][1], maker: info.Fees[0][1]}
+		if len(info.FeesMaker) > 0 && len(info.FeesMaker[0]) > 1 {
+			tier.maker = info.FeesMaker[0][1]
+		}
+		return tier, nil
+	}
+
+	volume, err := api.GetTradeVolume(true, pair)
+	if err != nil {
+		return feeTier{}, err
+	}
+	_, taker, ok := FirstResult(volume.Fees)
+	if !ok {
+		return feeTier{}, fmt.Errorf("no fee tier of pair %s", pair)
+	}
+	tier := feeTier{taker: taker.Fee, maker: taker.Fee}
+	if _, maker, ok := FirstResult(volume.FeesMaker); ok {
+		tier.maker = maker.Fee
+	}
+	return tier, nil
+}
+
+// defaultTokenRefreshMargin - default time before token expiration when cached token is refreshed
+const defaultTokenRefreshMargin = time.Minute
+
+// SetTokenRefreshMargin - sets time before expiration when cached websockets token is refreshed by WebSocketToken. Default: 1m.
+func (api *Kraken) SetTokenRefreshMargin(margin time.Duration) {
+	if api.token == nil {
+		return
+	}
+	api.token.mx.Lock()
+	api.token.margin = margin
+	api.token.mx.Unlock()
+}
+
+// WebSocketToken - returns cached websockets token and refreshes it a margin before expiration.
+// It's safe for concurrent use, so websocket subscribers could share one token.
+// Request is also bounded by request timeout and context of Kraken object (see WithTimeout and WithContext).
+func (api *Kraken) WebSocketToken(ctx context.Context) (string, error) {
+	ctx, cancel := api.mergeContext(ctx)
+	defer cancel()
+
+	cache := api.token
+	if cache == nil {
+		response := GetWebSocketTokenResponse{}
+		err := api.requestWithContext(ctx, "GetWebSocketsToken", true, nil, &response, "POST")
+		return response.Token, err
+	}
+
+	cache.mx.Lock()
+	defer cache.mx.Unlock()
+
+	now := time.Now
+	if cache.now != nil {
+		now = cache.now
+	}
+	margin := defaultTokenRefreshMargin
+	if cache.margin > 0 {
+		margin = cache.margin
+	}
+
+	if cache.token != "" && now().Add(margin).Before(cache.expiresAt) {
+		return cache.token, nil
+	}
+
+	response := GetWebSocketTokenResponse{}
+	if err := api.requestWithContext(ctx, "GetWebSocketsToken", true, nil, &response, "POST"); err != nil {
+		return "", err
+	}
+	cache.token = response.Token
+	cache.expiresAt = now().Add(time.Duration(response.Expires) * time.Second)
+	return cache.token, nil
+}
@@ -2,32 +2,56 @@ package rest
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ericlagergren/decimal"
 	"github.com/stretchr/testify/assert"
 )
 
 var (
-	depositMethodsJSON  = []byte(`{"error":[],"result":[{"method": "Ether (Hex)","limit": false,"fee": "0.0000000000","gen-address": true}]}`)
-	depositStatusesJSON = []byte(`{"error":[],"result":[{"method": "Ether (Hex)","aclass": "currency","asset": "XETH","refid": "sometest1","txid": "sometest2","info": "sometest3","amount": "6.91","fee": "0.0000000000","time": 1617014556,"status": "Success"}]}`)
-	balancesJSON        = []byte(`{"error":[],"result":{"ZUSD":"435.9135","USDT":"2.00000000","BSV":"0.0000053898"}}`)
-	tradeBalancesJSON   = []byte(`{"error":[],"result":{"eb":"33.50","tb":"33.50","m":"23.77","n":"4.3750","c":"11.8999","v":"12.2","e":"32.1","mf":"33.1","ml":"12.97"}}`)
-	openOrdersJSON      = []byte(`{"error":[],"result":{"open":{"OR3XZM-5EN2R-LS5X51":{"refid":null,"userref":null,"status":"open","opentm":1570622342.3552,"starttm":0,"expiretm":0,"descr":{"pair":"XBTEUR","type":"sell","ordertype":"limit","price":"7712.2","price2":"0","leverage":"4:1","order":"sell 1.10000000 XBTEUR @ limit 7712.2 with 4:1 leverage","close":""},"vol":"1.10000000","vol_exec":"0.00000000","cost":"0.00000","fee":"0.00000","price":"0.00000","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}}}}`)
-	closedOrdersJSON    = []byte(`{"error":[],"result":{"closed":{"OK46ER-A2BXK-YOLKE1":{"refid":null,"userref":null,"status":"canceled","reason":"User requested","opentm":1570623817.6537,"closetm":1570623823.9012,"starttm":0,"expiretm":0,"descr":{"pair":"ETHEUR","type":"buy","ordertype":"limit","price":"160.87","price2":"0","leverage":"4:1","order":"buy 21.00000000 ETHEUR @ limit 160.87 with 4:1 leverage","close":""},"vol":"21.00000000","vol_exec":"0.00000000","cost":"0.00000","fee":"0.00000","price":"0.00000","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}},"count":20}}`)
-	queryOrdersJSON     = []byte(`{"error":[],"result":{"OLNYE1-H3BBJ-JD2LGC":{"refid":null,"userref":null,"status":"canceled","reason":"User requested","opentm":1570623816.1101,"closetm":1570623819.639,"starttm":0,"expiretm":0,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"limit","price":"7920.9","price2":"0","leverage":"4:1","order":"buy 1.10000000 XBTUSD @ limit 7920.9 with 4:1 leverage","close":""},"vol":"1.10000000","vol_exec":"0.00000000","cost":"0.00000","fee":"0.00000","price":"0.00000","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}}}`)
-	tradeHistoryJSON    = []byte(`{"error":[],"result":{"trades":{"TO3MMA-BSBGV-XUV4A1":{"ordertxid":"OSQQQ5-MBKL6-O4YYE1","postxid":"TYE7IH-QCG76-BVMCM1","pair":"XXBTZUSD","time":1570477513.2,"type":"buy","ordertype":"limit","price":"7000.60000","cost":"1000.38301","fee":"0.00000","vol":"0.2","margin":"320","misc":"closing"}},"count":1}}`)
-	queryTradesJSON     = []byte(`{"error":[],"result":{"TO3MMA-BSBGV-XUV4A1":{"ordertxid":"OSQQQ5-MBKL6-O4YYE1","postxid":"TYE7IH-QCG76-BVMCM1","pair":"XXBTZUSD","time":1570477513.2,"type":"buy","ordertype":"limit","price":"7000.60000","cost":"1000.38301","fee":"0.00000","vol":"0.2","margin":"320","misc":"closing"}}}`)
-	openPositionsJSON   = []byte(`{"error":[],"result":{"TYE7IH-QCG76-BVMCM1":{"ordertxid":"OK7SOC-SGF3O-F54S51","posstatus":"open","pair":"XXBTZUSD","time":1569513333.0361,"type":"buy","ordertype":"limit","cost":"570.39712","fee":"39","vol":"7","vol_closed":"6.66208817","margin":"9.2","terms":"0.0100% per 4 hours","rollovertm":"1570638129","misc":"","oflags":""}}}`)
-	getLedgersJSON      = []byte(`{"error":[],"result":{"ledger":{"LGPNZQ-2SLSA-C7QCT1":{"refid":"TI2NBU-IICD2-BAVYO1","time":1570623111.9096,"type":"rollover","aclass":"currency","asset":"ZUSD","amount":"0.0000","fee":"0.7169","balance":"1.7326"}}}}`)
-	queryLedgerJSON     = []byte(`{"error":[],"result":{"LTCH4T-LG5FS-MKGVD1":{"refid":"TYE7IH-QCG76-BVMCM1","time":1570551111.2568,"type":"rollover","aclass":"currency","asset":"ZUSD","amount":"0.0000","fee":"0.4640","balance":"1.3540"}}}`)
-	getTradeVolumeJSON  = []byte(`{"error":[],"result":{"currency":"ZUSD","volume":"1000","fees":{"XXBTZUSD":{"fee":"0.1600","minfee":"0.1000","maxfee":"0.2600","nextfee":"0.1400","nextvolume":"2500000.0000","tiervolume":"1000000.0000"}},"fees_maker":{"XXBTZUSD":{"fee":"0.0600","minfee":"0.0000","maxfee":"0.1600","nextfee":"0.0400","nextvolume":"2500000.0000","tiervolume":"1000000.0000"}}}}`)
-	getWSTokenJSON      = []byte(`{"error":[],"result":{"token": "test", "expires": 900}}`)
+	depositMethodsJSON            = []byte(`{"error":[],"result":[{"method": "Ether (Hex)","limit": false,"fee": "0.0000000000","gen-address": true}]}`)
+	depositStatusesJSON           = []byte(`{"error":[],"result":[{"method": "Ether (Hex)","aclass": "currency","asset": "XETH","refid": "sometest1","txid": "sometest2","info": "sometest3","amount": "6.91","fee": "0.0000000000","time": 1617014556,"status": "Success"}]}`)
+	depositStatusesFirstPageJSON  = []byte(`{"error":[],"result":{"deposit":[{"method": "Ether (Hex)","aclass": "currency","asset": "XETH","refid": "sometest1","txid": "sometest2","info": "sometest3","amount": "6.91","fee": "0.0000000000","time": 1617014556,"status": "Success"}],"next_cursor":"MTIzNDU2Nzg5"}}`)
+	depositStatusesLastPageJSON   = []byte(`{"error":[],"result":{"deposit":[{"method": "Ether (Hex)","aclass": "currency","asset": "XETH","refid": "sometest4","txid": "sometest5","info": "sometest6","amount": "1.5","fee": "0.0000000000","time": 1617014000,"status": "Success"}]}}`)
+	withdrawStatusesFirstPageJSON = []byte(`{"error":[],"result":{"withdrawals":[{"method": "Bitcoin","aclass": "currency","asset": "XXBT","refid": "AGBSO6T-UFMTTQ-I7KGS6","txid": "THVRQM-33VKH-UCI7BS","info": "mzp6yUVMRxfasyfwzTZjjy38dHqMX7Z3GR","amount": "0.72485000","fee": "0.00015000","time": 1617014586,"status": "Pending"}],"next_cursor":"OTg3NjU0MzIx"}}`)
+	withdrawStatusesLastPageJSON  = []byte(`{"error":[],"result":{"withdrawals":[{"method": "Bitcoin","aclass": "currency","asset": "XXBT","refid": "AGBZNBO-5P2XSB-RFVF6J","txid": "KLETXZ-33VKH-UCI7BS","info": "mzp6yUVMRxfasyfwzTZjjy38dHqMX7Z3GR","amount": "0.10000000","fee": "0.00015000","time": 1617014000,"status": "Success"}]}}`)
+	balancesJSON                  = []byte(`{"error":[],"result":{"ZUSD":"435.9135","USDT":"2.00000000","BSV":"0.0000053898"}}`)
+	tradeBalancesJSON             = []byte(`{"error":[],"result":{"eb":"33.50","tb":"33.50","m":"23.77","n":"4.3750","c":"11.8999","v":"12.2","e":"32.1","mf":"33.1","ml":"12.97"}}`)
+	openOrdersJSON                = []byte(`{"error":[],"result":{"open":{"OR3XZM-5EN2R-LS5X51":{"refid":null,"userref":null,"status":"open","opentm":1570622342.3552,"starttm":0,"expiretm":0,"descr":{"pair":"XBTEUR","type":"sell","ordertype":"limit","price":"7712.2","price2":"0","leverage":"4:1","order":"sell 1.10000000 XBTEUR @ limit 7712.2 with 4:1 leverage","close":""},"vol":"1.10000000","vol_exec":"0.00000000","cost":"0.00000","fee":"0.00000","price":"0.00000","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}}}}`)
+	closedOrdersJSON              = []byte(`{"error":[],"result":{"closed":{"OK46ER-A2BXK-YOLKE1":{"refid":null,"userref":null,"status":"canceled","reason":"User requested","opentm":1570623817.6537,"closetm":1570623823.9012,"starttm":0,"expiretm":0,"descr":{"pair":"ETHEUR","type":"buy","ordertype":"limit","price":"160.87","price2":"0","leverage":"4:1","order":"buy 21.00000000 ETHEUR @ limit 160.87 with 4:1 leverage","close":""},"vol":"21.00000000","vol_exec":"0.00000000","cost":"0.00000","fee":"0.00000","price":"0.00000","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}},"count":20}}`)
+	queryOrdersJSON               = []byte(`{"error":[],"result":{"OLNYE1-H3BBJ-JD2LGC":{"refid":null,"userref":null,"status":"canceled","reason":"User requested","opentm":1570623816.1101,"closetm":1570623819.639,"starttm":0,"expiretm":0,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"limit","price":"7920.9","price2":"0","leverage":"4:1","order":"buy 1.10000000 XBTUSD @ limit 7920.9 with 4:1 leverage","close":""},"vol":"1.10000000","vol_exec":"0.00000000","cost":"0.00000","fee":"0.00000","price":"0.00000","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}}}`)
+	tradeHistoryJSON              = []byte(`{"error":[],"result":{"trades":{"TO3MMA-BSBGV-XUV4A1":{"ordertxid":"OSQQQ5-MBKL6-O4YYE1","postxid":"TYE7IH-QCG76-BVMCM1","pair":"XXBTZUSD","time":1570477513.2,"type":"buy","ordertype":"limit","price":"7000.60000","cost":"1000.38301","fee":"0.00000","vol":"0.2","margin":"320","misc":"closing"}},"count":1}}`)
+	queryTradesJSON               = []byte(`{"error":[],"result":{"TO3MMA-BSBGV-XUV4A1":{"ordertxid":"OSQQQ5-MBKL6-O4YYE1","postxid":"TYE7IH-QCG76-BVMCM1","pair":"XXBTZUSD","time":1570477513.2,"type":"buy","ordertype":"limit","price":"7000.60000","cost":"1000.38301","fee":"0.00000","vol":"0.2","margin":"320","misc":"closing"}}}`)
+	openPositionsJSON             = []byte(`{"error":[],"result":{"TYE7IH-QCG76-BVMCM1":{"ordertxid":"OK7SOC-SGF3O-F54S51","posstatus":"open","pair":"XXBTZUSD","time":1569513333.0361,"type":"buy","ordertype":"limit","cost":"570.39712","fee":"39","vol":"7","vol_closed":"6.66208817","margin":"9.2","terms":"0.0100% per 4 hours","rollovertm":"1570638129","misc":"","oflags":""}}}`)
+	getLedgersJSON                = []byte(`{"error":[],"result":{"ledger":{"LGPNZQ-2SLSA-C7QCT1":{"refid":"TI2NBU-IICD2-BAVYO1","time":1570623111.9096,"type":"rollover","aclass":"currency","asset":"ZUSD","amount":"0.0000","fee":"0.7169","balance":"1.7326"}}}}`)
+	queryLedgerJSON               = []byte(`{"error":[],"result":{"LTCH4T-LG5FS-MKGVD1":{"refid":"TYE7IH-QCG76-BVMCM1","time":1570551111.2568,"type":"rollover","aclass":"currency","asset":"ZUSD","amount":"0.0000","fee":"0.4640","balance":"1.3540"}}}`)
+	getTradeVolumeJSON            = []byte(`{"error":[],"result":{"currency":"ZUSD","volume":"1000","fees":{"XXBTZUSD":{"fee":"0.1600","minfee":"0.1000","maxfee":"0.2600","nextfee":"0.1400","nextvolume":"2500000.0000","tiervolume":"1000000.0000"}},"fees_maker":{"XXBTZUSD":{"fee":"0.0600","minfee":"0.0000","maxfee":"0.1600","nextfee":"0.0400","nextvolume":"2500000.0000","tiervolume":"1000000.0000"}}}}`)
+	addOrderJSON                  = []byte(`{"error":[],"result":{"descr":{"order":"buy 1.25000000 XBTUSD @ limit 27500.0"},"txid":["OUF4EM-FRGI2-MQMWZD"]}}`)
+	addTrailingStopOrderJSON      = []byte(`{"error":[],"result":{"descr":{"pair":"XBTUSD","type":"sell","ordertype":"trailing-stop","price":"+5.0000%","price2":"0","order":"sell 1.25000000 XBTUSD @ trailing stop +5.0000%"},"txid":["OUF4EM-FRGI2-MQMWZE"]}}`)
+	balancesExJSON                = []byte(`{"error":[],"result":{"ZUSD":{"balance":"435.9135","hold_trade":"0"},"DOT":{"balance":"10.5","hold_trade":"0"},"DOT.S":{"balance":"20"}}}`)
+	getWSTokenJSON                = []byte(`{"error":[],"result":{"token": "test", "expires": 900}}`)
 )
 
+func requestForm(t *testing.T, req *http.Request) url.Values {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
 func TestKraken_GetDepositMethods(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -120,6 +144,126 @@ func TestKraken_GetDepositStatuses(t *testing.T) {
 	}
 }
 
+func TestKraken_GetDepositStatusPaged(t *testing.T) {
+	firstPage := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(depositStatusesFirstPageJSON)),
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: firstPage,
+	}
+	got, cursor, err := api.GetDepositStatusPaged("XETH", "", "")
+	if err != nil {
+		t.Errorf("Kraken.GetDepositStatusPaged() error = %v", err)
+		return
+	}
+	assert.Len(t, got, 1)
+	assert.Equal(t, "MTIzNDU2Nzg5", cursor)
+	assert.Equal(t, "true", requestForm(t, firstPage.Request).Get("cursor"))
+
+	secondPage := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(depositStatusesLastPageJSON)),
+		},
+	}
+	api.client = secondPage
+	got, cursor, err = api.GetDepositStatusPaged("XETH", "", cursor)
+	if err != nil {
+		t.Errorf("Kraken.GetDepositStatusPaged() error = %v", err)
+		return
+	}
+	assert.Len(t, got, 1)
+	assert.Equal(t, "", cursor)
+	assert.Equal(t, "MTIzNDU2Nzg5", requestForm(t, secondPage.Request).Get("cursor"))
+}
+
+func TestKraken_GetWithdrawStatusPaged(t *testing.T) {
+	firstPage := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(withdrawStatusesFirstPageJSON)),
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: firstPage,
+	}
+	got, cursor, err := api.GetWithdrawStatusPaged("XXBT", "Bitcoin", "")
+	if err != nil {
+		t.Errorf("Kraken.GetWithdrawStatusPaged() error = %v", err)
+		return
+	}
+	assert.Len(t, got, 1)
+	assert.Equal(t, "AGBSO6T-UFMTTQ-I7KGS6", got[0].Refid)
+	assert.Equal(t, "OTg3NjU0MzIx", cursor)
+	form := requestForm(t, firstPage.Request)
+	assert.Equal(t, "true", form.Get("cursor"))
+	assert.Equal(t, "XXBT", form.Get("asset"))
+	assert.Equal(t, "Bitcoin", form.Get("method"))
+
+	secondPage := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(withdrawStatusesLastPageJSON)),
+		},
+	}
+	api.client = secondPage
+	got, cursor, err = api.GetWithdrawStatusPaged("XXBT", "Bitcoin", cursor)
+	if err != nil {
+		t.Errorf("Kraken.GetWithdrawStatusPaged() error = %v", err)
+		return
+	}
+	assert.Len(t, got, 1)
+	assert.Equal(t, "AGBZNBO-5P2XSB-RFVF6J", got[0].Refid)
+	assert.Equal(t, "", cursor)
+	assert.Equal(t, "OTg3NjU0MzIx", requestForm(t, secondPage.Request).Get("cursor"))
+}
+
+func TestKraken_AvailableBalance(t *testing.T) {
+	tests := []struct {
+		name  string
+		asset string
+		want  float64
+	}{
+		{
+			name:  "Held amount is subtracted",
+			asset: "XXBT",
+			want:  1.25,
+		}, {
+			name:  "No hold",
+			asset: "DOT.S",
+			want:  20,
+		}, {
+			name:  "Unknown asset",
+			asset: "XETH",
+			want:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &Kraken{
+				secret: deadbeaf,
+				client: &httpMock{
+					Response: &http.Response{
+						StatusCode: 200,
+						Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"XXBT":{"balance":"2.0000000000","hold_trade":"0.7500000000"},"DOT.S":{"balance":"20"}}}`)),
+					},
+				},
+			}
+			got, err := api.AvailableBalance(tt.asset)
+			if err != nil {
+				t.Errorf("Kraken.AvailableBalance() error = %v", err)
+				return
+			}
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
 func TestKraken_GetAccountBalances(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -175,6 +319,86 @@ func TestKraken_GetAccountBalances(t *testing.T) {
 	}
 }
 
+func TestKraken_ConsolidatedBalance(t *testing.T) {
+	api := &Kraken{
+		secret: deadbeaf,
+		client: &httpSequenceMock{
+			Responses: []*http.Response{
+				{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader(balancesExJSON)),
+				},
+			},
+		},
+	}
+	got, err := api.ConsolidatedBalance()
+	if err != nil {
+		t.Errorf("Kraken.ConsolidatedBalance() error = %v", err)
+		return
+	}
+	if !assert.Len(t, got, 2) {
+		return
+	}
+	assert.Equal(t, "10.5", got["DOT"].Spot.String())
+	assert.Equal(t, "20", got["DOT"].Staked.String())
+	assert.Equal(t, "30.5", got["DOT"].Total.String())
+	assert.Equal(t, "435.9135", got["ZUSD"].Spot.String())
+	assert.Equal(t, "0", got["ZUSD"].Staked.String())
+	assert.Equal(t, "435.9135", got["ZUSD"].Total.String())
+}
+
+func TestKraken_ConsolidatedBalance_suffixes(t *testing.T) {
+	mock := &httpSequenceMock{
+		Responses: []*http.Response{
+			{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"DOT":{"balance":"10.5"},"DOT.S":{"balance":"21.37"},"DOT.P":{"balance":"7"},"USDT.M":{"balance":"5"},"USDT":{"balance":"1"},"ETH.F":{"balance":"3"},"ETH.B":{"balance":"0.5"},"SOL.HOLD":{"balance":"2"}}}`)),
+			},
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+	got, err := api.ConsolidatedBalance()
+	if err != nil {
+		t.Errorf("Kraken.ConsolidatedBalance() error = %v", err)
+		return
+	}
+	assert.Len(t, mock.Requests, 1)
+	if !assert.Len(t, got, 3) {
+		return
+	}
+	assert.Equal(t, "21.37", got["DOT"].Staked.String())
+	assert.Equal(t, "31.87", got["DOT"].Total.String())
+	assert.Equal(t, "5", got["USDT"].Staked.String())
+	assert.Equal(t, "6", got["USDT"].Total.String())
+	assert.Equal(t, "0", got["ETH"].Spot.String())
+	assert.Equal(t, "3.5", got["ETH"].Earn.String())
+	assert.Equal(t, "3.5", got["ETH"].Total.String())
+}
+
+func TestKraken_ConsolidatedBalance_decimalContext(t *testing.T) {
+	defer SetDecimalContext(DecimalContext())
+	SetDecimalContext(decimal.Context{Precision: 40})
+
+	api := &Kraken{
+		secret: deadbeaf,
+		client: &httpMock{
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewReader(balancesExJSON)),
+			},
+		},
+	}
+	got, err := api.ConsolidatedBalance()
+	if err != nil {
+		t.Errorf("Kraken.ConsolidatedBalance() error = %v", err)
+		return
+	}
+	assert.Equal(t, 40, got["DOT"].Total.Context.Precision)
+}
+
 func TestKraken_GetTradeBalance(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -371,7 +595,7 @@ func TestKraken_GetClosedOrders(t *testing.T) {
 					Response: tt.resp,
 				},
 			}
-			got, err := api.GetClosedOrders(false, "", 0, 0)
+			got, err := api.GetClosedOrders(false, "", "", "", false)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Kraken.GetClosedOrders() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -383,6 +607,68 @@ func TestKraken_GetClosedOrders(t *testing.T) {
 	}
 }
 
+func TestKraken_GetClosedOrdersWithoutCount(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":[],"result":{"closed":{"OK46ER-A2BXK-YOLKE1":{"status":"canceled","opentm":1570623817.6537,"closetm":1570623823.9012,"descr":{"pair":"ETHEUR","type":"buy","ordertype":"limit","price":"160.87","price2":"0","leverage":"4:1","order":"buy 21.00000000 ETHEUR @ limit 160.87 with 4:1 leverage","close":""},"vol":"21.00000000","vol_exec":"0.00000000","cost":"0.00000","fee":"0.00000","price":"0.00000","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}}}}`))),
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+	got, err := api.GetClosedOrders(false, "", "", "", true)
+	if err != nil {
+		t.Errorf("Kraken.GetClosedOrders() error = %v", err)
+		return
+	}
+	assert.Equal(t, int64(0), got.Count)
+	assert.Len(t, got.Orders, 1)
+	assert.Equal(t, "true", requestForm(t, mock.Request).Get("without_count"))
+}
+
+func TestKraken_GetClosedOrdersBounds(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		end   string
+	}{
+		{
+			name:  "Timestamp bounds",
+			start: "1570623817",
+			end:   "1570623900",
+		}, {
+			name:  "Txid bounds",
+			start: "OK46ER-A2BXK-YOLKE1",
+			end:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpMock{
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader(closedOrdersJSON)),
+				},
+			}
+			api := &Kraken{
+				secret: deadbeaf,
+				client: mock,
+			}
+			if _, err := api.GetClosedOrders(false, "", tt.start, tt.end, false); err != nil {
+				t.Errorf("Kraken.GetClosedOrders() error = %v", err)
+				return
+			}
+			form := requestForm(t, mock.Request)
+			assert.Equal(t, tt.start, form.Get("start"))
+			_, ok := form["end"]
+			assert.Equal(t, tt.end != "", ok)
+			assert.Equal(t, tt.end, form.Get("end"))
+		})
+	}
+}
+
 func TestKraken_QueryOrders(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -458,6 +744,100 @@ func TestKraken_QueryOrders(t *testing.T) {
 	}
 }
 
+func TestKraken_QueryOrdersWithTrades(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"OQCLML-BW3P3-BUCMWZ":{"refid":null,"userref":0,"status":"closed","opentm":1616666559.8974,"closetm":1616666559.9037,"starttm":0,"expiretm":0,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"market","price":"0","price2":"0","leverage":"none","order":"buy 1.25000000 XBTUSD @ market","close":""},"vol":"1.25000000","vol_exec":"1.25000000","cost":"27526.2","fee":"26.2","price":"22021.0","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq","trades":["TZX2WP-XSEOP-FP7WYR","TJUW2K-FLX2N-AR2FLU"]}}}`)),
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+	got, err := api.QueryOrders(true, "", "OQCLML-BW3P3-BUCMWZ")
+	if err != nil {
+		t.Errorf("Kraken.QueryOrders() error = %v", err)
+		return
+	}
+	assert.Equal(t, "true", requestForm(t, mock.Request).Get("trades"))
+	assert.Equal(t, []string{"TZX2WP-XSEOP-FP7WYR", "TJUW2K-FLX2N-AR2FLU"}, got["OQCLML-BW3P3-BUCMWZ"].Trades)
+}
+
+func TestKraken_QueryOrdersDecimal(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"OBCMZD-JIEE7-77TH3F":{"status":"closed","descr":{"pair":"XBTUSD","price":"0","price2":"0"},"vol":"1.123456789","vol_exec":"1.123456789","cost":"123456789.123456789","fee":"0.000000001","price":"109890213.2","stopprice":"0","limitprice":"0"}}}`)),
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+
+	got, err := api.QueryOrdersDecimal("", "OBCMZD-JIEE7-77TH3F")
+	if err != nil {
+		t.Errorf("Kraken.QueryOrdersDecimal() error = %v", err)
+		return
+	}
+	order, ok := got["OBCMZD-JIEE7-77TH3F"]
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, "123456789.123456789", order.Cost.String())
+	assert.Equal(t, "1.123456789", order.VolumeExecuted.String())
+	assert.Equal(t, 0, order.Fee.Cmp(decimal.New(1, 9)))
+	assert.Equal(t, "OBCMZD-JIEE7-77TH3F", requestForm(t, mock.Request).Get("txid"))
+
+	_, err = api.QueryOrdersDecimal("")
+	assert.NotNil(t, err)
+}
+
+func TestKraken_QueryOrdersBatched(t *testing.T) {
+	txIDs := make([]string, 120)
+	for i := range txIDs {
+		txIDs[i] = fmt.Sprintf("O%05d-AAAAA-AAAAAA", i)
+	}
+	mock := &httpSequenceMock{}
+	for start := 0; start < len(txIDs); start += maxQueryOrders {
+		end := start + maxQueryOrders
+		if end > len(txIDs) {
+			end = len(txIDs)
+		}
+		orders := make([]string, 0, end-start)
+		for _, txID := range txIDs[start:end] {
+			orders = append(orders, fmt.Sprintf(`"%s":{"status":"closed","descr":{"pair":"XBTUSD","price":"0","price2":"0"},"vol":"1","vol_exec":"1","cost":"1","fee":"0","price":"1","stopprice":"0","limitprice":"0"}`, txID))
+		}
+		mock.Responses = append(mock.Responses, &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{` + strings.Join(orders, ",") + `}}`)),
+		})
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+		pause:  time.Millisecond,
+	}
+
+	got, err := api.QueryOrders(false, "", txIDs...)
+	if err != nil {
+		t.Errorf("Kraken.QueryOrders() error = %v", err)
+		return
+	}
+	assert.Len(t, got, len(txIDs))
+	if !assert.Len(t, mock.Requests, 3) {
+		return
+	}
+	requested := 0
+	for _, req := range mock.Requests {
+		ids := strings.Split(requestForm(t, req).Get("txid"), ",")
+		assert.True(t, len(ids) <= maxQueryOrders)
+		requested += len(ids)
+	}
+	assert.Equal(t, len(txIDs), requested)
+}
+
 func TestKraken_GetTradesHistory(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -509,7 +889,7 @@ func TestKraken_GetTradesHistory(t *testing.T) {
 					Response: tt.resp,
 				},
 			}
-			got, err := api.GetTradesHistory("", false, 0, 0)
+			got, err := api.GetTradesHistory("", false, "", "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Kraken.GetTradesHistory() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -521,6 +901,26 @@ func TestKraken_GetTradesHistory(t *testing.T) {
 	}
 }
 
+func TestKraken_GetTradesHistoryBounds(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(tradeHistoryJSON)),
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+	if _, err := api.GetTradesHistory("", false, "TO3MMA-BSBGV-XUV4A1", "1570477600"); err != nil {
+		t.Errorf("Kraken.GetTradesHistory() error = %v", err)
+		return
+	}
+	form := requestForm(t, mock.Request)
+	assert.Equal(t, "TO3MMA-BSBGV-XUV4A1", form.Get("start"))
+	assert.Equal(t, "1570477600", form.Get("end"))
+}
+
 func TestKraken_QueryTrades(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -581,6 +981,55 @@ func TestKraken_QueryTrades(t *testing.T) {
 	}
 }
 
+func TestKraken_QueryTradesBatched(t *testing.T) {
+	txIDs := make([]string, 45)
+	for i := range txIDs {
+		txIDs[i] = fmt.Sprintf("T%05d-AAAAA-AAAAAA", i)
+	}
+	mock := &httpSequenceMock{}
+	for start := 0; start < len(txIDs); start += maxQueryTrades {
+		end := start + maxQueryTrades
+		if end > len(txIDs) {
+			end = len(txIDs)
+		}
+		trades := make([]string, 0, end-start)
+		for _, txID := range txIDs[start:end] {
+			trades = append(trades, fmt.Sprintf(`"%s":{"ordertxid":"OSQQQ5-MBKL6-O4YYE1","pair":"XXBTZUSD","time":1570477513.2,"type":"buy","ordertype":"limit","price":"7000.6","cost":"1000.38301","fee":"0","vol":"0.2","margin":"0","misc":""}`, txID))
+		}
+		mock.Responses = append(mock.Responses, &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{` + strings.Join(trades, ",") + `}}`)),
+		})
+	}
+	pause := 20 * time.Millisecond
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+		pause:  pause,
+	}
+
+	started := time.Now()
+	got, err := api.QueryTrades(true, txIDs...)
+	if err != nil {
+		t.Errorf("Kraken.QueryTrades() error = %v", err)
+		return
+	}
+	assert.True(t, time.Since(started) >= 2*pause)
+	assert.Len(t, got, len(txIDs))
+	if !assert.Len(t, mock.Requests, 3) {
+		return
+	}
+	requested := 0
+	for _, req := range mock.Requests {
+		form := requestForm(t, req)
+		ids := strings.Split(form.Get("txid"), ",")
+		assert.True(t, len(ids) <= maxQueryTrades)
+		assert.Equal(t, "true", form.Get("trades"))
+		requested += len(ids)
+	}
+	assert.Equal(t, len(txIDs), requested)
+}
+
 func TestKraken_GetOpenPositions(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -702,6 +1151,73 @@ func TestKraken_GetLedgersInfo(t *testing.T) {
 	}
 }
 
+func TestKraken_GetLedgersInfoByClass(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"ledger":{"LGPNZQ-2SLSA-C7QCT1":{"refid":"TI2NBU-IICD2-BAVYO1","time":1570623111.9096,"type":"deposit","aclass":"currency","asset":"ZUSD","amount":"100.0000","fee":"0.0000","balance":"100.0000"},"L4UESK-KG3EQ-UFO4T5":{"refid":"TJKLXX-PGMUI-4NTLXU","time":1570623112.1234,"type":"transfer","aclass":"futures","asset":"XBT","amount":"0.0100000000","fee":"0.0000000000","balance":"0.0100000000"}},"count":2}}`)),
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+	got, err := api.GetLedgersInfoByClass(AssetClassFutures, LedgerTypeDeposit, 0, 0)
+	if err != nil {
+		t.Errorf("Kraken.GetLedgersInfoByClass() error = %v", err)
+		return
+	}
+	form := requestForm(t, mock.Request)
+	assert.Equal(t, AssetClassFutures, form.Get("aclass"))
+	assert.Equal(t, LedgerTypeDeposit, form.Get("type"))
+
+	futures := got.ByAssetClass(AssetClassFutures)
+	if assert.Len(t, futures, 1) {
+		assert.Equal(t, "XBT", futures["L4UESK-KG3EQ-UFO4T5"].Asset)
+	}
+	currency := got.ByAssetClass(AssetClassCurrency)
+	if assert.Len(t, currency, 1) {
+		assert.Equal(t, "ZUSD", currency["LGPNZQ-2SLSA-C7QCT1"].Asset)
+	}
+}
+
+func TestKraken_GetLedgersInfo_forwardsType(t *testing.T) {
+	tests := []struct {
+		name       string
+		ledgerType string
+		wantType   string
+		wantSet    bool
+	}{
+		{name: "type given", ledgerType: LedgerTypeDeposit, wantType: LedgerTypeDeposit, wantSet: true},
+		{name: "type omitted", ledgerType: "", wantSet: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpMock{
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"ledger":{},"count":0}}`)),
+				},
+			}
+			api := &Kraken{
+				secret: deadbeaf,
+				client: mock,
+			}
+			if _, err := api.GetLedgersInfo(tt.ledgerType, 0, 0, "XXBT"); err != nil {
+				t.Errorf("Kraken.GetLedgersInfo() error = %v", err)
+				return
+			}
+			form := requestForm(t, mock.Request)
+			_, ok := form["type"]
+			assert.Equal(t, tt.wantSet, ok)
+			assert.Equal(t, tt.wantType, form.Get("type"))
+			assert.Equal(t, "XXBT", form.Get("assets"))
+			_, ok = form["aclass"]
+			assert.False(t, ok)
+		})
+	}
+}
+
 func TestKraken_QueryLedgers(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -873,3 +1389,734 @@ func TestKraken_GetWebSocketsToken(t *testing.T) {
 		})
 	}
 }
+
+func TestKraken_AddOrder(t *testing.T) {
+	deadline := time.Date(2021, time.April, 1, 3, 18, 45, 0, time.FixedZone("UTC+3", 3*60*60))
+	tests := []struct {
+		name         string
+		args         map[string]interface{}
+		wantDeadline string
+	}{
+		{
+			name: "With deadline",
+			args: map[string]interface{}{
+				ArgDeadline: deadline,
+			},
+			wantDeadline: "2021-04-01T00:18:45Z",
+		}, {
+			name: "Zero deadline",
+			args: map[string]interface{}{
+				ArgDeadline: time.Time{},
+			},
+			wantDeadline: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpMock{
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader(addOrderJSON)),
+				},
+			}
+			api := &Kraken{
+				secret: deadbeaf,
+				client: mock,
+			}
+			got, err := api.AddOrder("XXBTZUSD", Buy, OTLimit, 1.25, tt.args)
+			if err != nil {
+				t.Errorf("Kraken.AddOrder() error = %v", err)
+				return
+			}
+			assert.Equal(t, []string{"OUF4EM-FRGI2-MQMWZD"}, got.TransactionIds)
+
+			form := requestForm(t, mock.Request)
+			assert.Equal(t, tt.wantDeadline, form.Get(ArgDeadline))
+			_, ok := form[ArgDeadline]
+			assert.Equal(t, tt.wantDeadline != "", ok)
+		})
+	}
+}
+
+func TestKraken_CancelByUserRef(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":[],"result":{"count":3}}`))),
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+	got, err := api.CancelByUserRef(145)
+	if err != nil {
+		t.Errorf("Kraken.CancelByUserRef() error = %v", err)
+		return
+	}
+	assert.Equal(t, int64(3), got.Count)
+
+	form := requestForm(t, mock.Request)
+	assert.Equal(t, "145", form.Get("txid"))
+	assert.True(t, strings.HasSuffix(mock.Request.URL.Path, "/CancelOrder"))
+}
+
+func TestKraken_EarnStrategies(t *testing.T) {
+	pages := []string{
+		`{"error":[],"result":{"next_cursor":"2","items":[{"id":"ESRFUO3-Q62XD-WIOIL7","asset":"DOT","lock_type":{"type":"instant","payout_frequency":604800},"apr_estimate":{"low":"8.0000","high":"12.0000"},"user_min_allocation":"0.01","allocation_fee":"0.0000","deallocation_fee":"0.0000","auto_compound":{"type":"enabled"},"yield_source":{"type":"staking"},"can_allocate":true,"can_deallocate":true}]}}`,
+		`{"error":[],"result":{"next_cursor":null,"items":[{"id":"ES2FUO3-BONDED","asset":"DOT","lock_type":{"type":"bonded","payout_frequency":604800,"bonding_period":0,"unbonding_period":2419200},"user_min_allocation":"1","allocation_fee":"0.0000","deallocation_fee":"0.0000","can_allocate":true,"can_deallocate":false}]}}`,
+	}
+	mock := &httpSequenceMock{}
+	for _, page := range pages {
+		mock.Responses = append(mock.Responses, &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader([]byte(page))),
+		})
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+	got, err := api.EarnStrategies("DOT")
+	if err != nil {
+		t.Errorf("Kraken.EarnStrategies() error = %v", err)
+		return
+	}
+	if !assert.Len(t, got, 2) {
+		return
+	}
+	assert.Equal(t, "ESRFUO3-Q62XD-WIOIL7", got[0].ID)
+	assert.Equal(t, EarnLockInstant, got[0].LockType.Type)
+	assert.Equal(t, "12.0000", got[0].APREstimate.High.String())
+	assert.Equal(t, EarnLockBonded, got[1].LockType.Type)
+	assert.Equal(t, int64(2419200), got[1].LockType.UnbondingPeriod)
+	assert.Nil(t, got[1].APREstimate)
+	assert.False(t, got[1].CanDeallocate)
+
+	assert.Equal(t, "DOT", requestForm(t, mock.Requests[0]).Get("asset"))
+	assert.Equal(t, "2", requestForm(t, mock.Requests[1]).Get("cursor"))
+}
+
+func TestKraken_EarnAllocate(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":[],"result":true}`))),
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+	got, err := api.EarnAllocate("ESRFUO3-Q62XD-WIOIL7", 12.5)
+	if err != nil {
+		t.Errorf("Kraken.EarnAllocate() error = %v", err)
+		return
+	}
+	assert.True(t, got)
+
+	form := requestForm(t, mock.Request)
+	assert.Equal(t, "ESRFUO3-Q62XD-WIOIL7", form.Get("strategy_id"))
+	assert.Equal(t, "12.5", form.Get("amount"))
+	assert.True(t, strings.HasSuffix(mock.Request.URL.Path, "/Earn/Allocate"))
+
+	_, err = api.EarnDeallocate("", 1)
+	assert.NotNil(t, err)
+}
+
+func TestKraken_EarnAllocateStatus(t *testing.T) {
+	mock := &httpSequenceMock{
+		Responses: []*http.Response{
+			{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"pending":true}}`)),
+			}, {
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"pending":false}}`)),
+			},
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+
+	for _, want := range []bool{true, false} {
+		got, err := api.EarnAllocateStatus("ESRFUO3-Q62XD-WIOIL7")
+		if err != nil {
+			t.Errorf("Kraken.EarnAllocateStatus() error = %v", err)
+			return
+		}
+		assert.Equal(t, want, got.Pending)
+	}
+	assert.Len(t, mock.Requests, 2)
+	assert.True(t, strings.HasSuffix(mock.Requests[0].URL.Path, "/Earn/AllocateStatus"))
+	assert.Equal(t, "ESRFUO3-Q62XD-WIOIL7", requestForm(t, mock.Requests[1]).Get("strategy_id"))
+
+	_, err := api.EarnDeallocateStatus("")
+	assert.NotNil(t, err)
+}
+
+func TestKraken_EarnAllocations(t *testing.T) {
+	json := []byte(`{"error":[],"result":{"converted_asset":"USD","total_allocated":"49.2398","total_rewarded":"0.0675","items":[{"strategy_id":"ESDQCOL-WTZEU-NU55QF","native_asset":"ETH","amount_allocated":{"bonding":{"native":"0.0210000000","converted":"39.0645","allocation_count":2},"total":{"native":"0.0265000000","converted":"49.2398"}},"total_rewarded":{"native":"0","converted":"0.0000"}}]}}`)
+	api := &Kraken{
+		secret: deadbeaf,
+		client: &httpMock{
+			Response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewReader(json)),
+			},
+		},
+	}
+	got, err := api.EarnAllocations()
+	if err != nil {
+		t.Errorf("Kraken.EarnAllocations() error = %v", err)
+		return
+	}
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, "ETH", got[0].NativeAsset)
+		assert.Equal(t, "0.0265000000", got[0].AmountAllocated.Total.Native.String())
+		assert.Equal(t, "39.0645", got[0].AmountAllocated.Bonding.Converted.String())
+		assert.Nil(t, got[0].AmountAllocated.Unbonding)
+	}
+}
+
+func TestKraken_EstimateFee(t *testing.T) {
+	mock := &httpSequenceMock{
+		Responses: []*http.Response{{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(getTradeVolumeJSON)),
+		}},
+	}
+	api := &Kraken{
+		key:    "key",
+		secret: deadbeaf,
+		client: mock,
+		fees:   &feeCache{},
+	}
+
+	taker, err := api.EstimateFee("XBTUSD", 0.5, 30000, false)
+	if err != nil {
+		t.Errorf("Kraken.EstimateFee() error = %v", err)
+		return
+	}
+	assert.InDelta(t, 24.0, taker, 1e-9)
+
+	maker, err := api.EstimateFee("XBTUSD", 0.5, 30000, true)
+	if err != nil {
+		t.Errorf("Kraken.EstimateFee() error = %v", err)
+		return
+	}
+	assert.InDelta(t, 9.0, maker, 1e-9)
+	assert.Len(t, mock.Requests, 1)
+}
+
+func TestKraken_EstimateFee_defaultSchedule(t *testing.T) {
+	json := []byte(`{"error":[],"result":{"XXBTZUSD":{"fees":[[0,0.26],[50000,0.24]],"fees_maker":[[0,0.16],[50000,0.14]],"fee_volume_currency":"ZUSD"}}}`)
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(json)),
+		},
+	}
+	api := &Kraken{
+		client: mock,
+	}
+
+	fee, err := api.EstimateFee("XBTUSD", 0.1, 10000, false)
+	if err != nil {
+		t.Errorf("Kraken.EstimateFee() error = %v", err)
+		return
+	}
+	assert.InDelta(t, 2.6, fee, 1e-9)
+	assert.Equal(t, AssetPairsInfoFees, mock.Request.URL.Query().Get("info"))
+}
+
+func TestKraken_AddOrderDisplayVolume(t *testing.T) {
+	tests := []struct {
+		name      string
+		orderType string
+		args      map[string]interface{}
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "Limit order with display volume",
+			orderType: OTLimit,
+			args: map[string]interface{}{
+				ArgDisplayVolume: 0.25,
+			},
+			want:    "0.25000000",
+			wantErr: false,
+		}, {
+			name:      "Display volume is not less than volume",
+			orderType: OTLimit,
+			args: map[string]interface{}{
+				ArgDisplayVolume: 1.25,
+			},
+			wantErr: true,
+		}, {
+			name:      "Market order with display volume",
+			orderType: OTMarket,
+			args: map[string]interface{}{
+				ArgDisplayVolume: 0.25,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpMock{
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader(addOrderJSON)),
+				},
+			}
+			api := &Kraken{
+				secret: deadbeaf,
+				client: mock,
+			}
+			_, err := api.AddOrder("XXBTZUSD", Buy, tt.orderType, 1.25, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Kraken.AddOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, requestForm(t, mock.Request).Get(ArgDisplayVolume))
+			}
+		})
+	}
+}
+
+func TestKraken_AddOrderClientOrderID(t *testing.T) {
+	clOrdID := "6d1b345e-2821-40e2-ad83-4ecb18a06876"
+	mock := &httpSequenceMock{
+		Responses: []*http.Response{
+			{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewReader(addOrderJSON)),
+			}, {
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"error":[],"result":{"OUF4EM-FRGI2-MQMWZD":{"refid":null,"userref":null,"cl_ord_id":"` + clOrdID + `","status":"open","opentm":1616666559.8974,"starttm":0,"expiretm":0,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"limit","price":"27500.0","price2":"0","leverage":"none","order":"buy 1.25000000 XBTUSD @ limit 27500.0","close":""},"vol":"1.25000000","vol_exec":"0.00000000","cost":"0.00000","fee":"0.00000","price":"0.00000","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}}}`)),
+			},
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+	added, err := api.AddOrder("XXBTZUSD", SideBuy, OrderTypeLimit, 1.25, map[string]interface{}{
+		"price":          "27500.0",
+		ArgClientOrderID: clOrdID,
+	})
+	if err != nil {
+		t.Errorf("Kraken.AddOrder() error = %v", err)
+		return
+	}
+	assert.Equal(t, clOrdID, requestForm(t, mock.Requests[0]).Get("cl_ord_id"))
+
+	got, err := api.QueryOrders(false, "", added.TransactionIds...)
+	if err != nil {
+		t.Errorf("Kraken.QueryOrders() error = %v", err)
+		return
+	}
+	assert.Equal(t, clOrdID, got["OUF4EM-FRGI2-MQMWZD"].ClientOrderID)
+
+	_, err = api.AddOrder("XXBTZUSD", SideBuy, OrderTypeLimit, 1.25, map[string]interface{}{
+		ArgClientOrderID: clOrdID,
+		ArgUserRef:       int64(1),
+	})
+	assert.NotNil(t, err)
+	assert.Len(t, mock.Requests, 2)
+}
+
+func TestKraken_AddOrderIdempotent(t *testing.T) {
+	clOrdID := "6d1b345e-2821-40e2-ad83-4ecb18a06876"
+	response := func(body string) *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+	existing := `{"OUF4EM-FRGI2-MQMWZD":{"refid":null,"userref":null,"cl_ord_id":"` + clOrdID + `","status":"%s","opentm":1616666559.8974,"starttm":0,"expiretm":0,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"limit","price":"27500.0","price2":"0","leverage":"none","order":"buy 1.25000000 XBTUSD @ limit 27500.0","close":""},"vol":"1.25000000","vol_exec":"0.00000000","cost":"0.00000","fee":"0.00000","price":"0.00000","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}}`
+	tests := []struct {
+		name      string
+		responses []*http.Response
+		methods   []string
+	}{
+		{
+			name: "Open order exists",
+			responses: []*http.Response{
+				response(`{"error":[],"result":{"open":` + fmt.Sprintf(existing, "open") + `}}`),
+			},
+			methods: []string{"OpenOrders"},
+		}, {
+			name: "Closed order exists",
+			responses: []*http.Response{
+				response(`{"error":[],"result":{"open":{}}}`),
+				response(`{"error":[],"result":{"count":0,"closed":` + fmt.Sprintf(existing, "closed") + `}}`),
+			},
+			methods: []string{"OpenOrders", "ClosedOrders"},
+		}, {
+			name: "Order doesn't exist",
+			responses: []*http.Response{
+				response(`{"error":[],"result":{"open":{}}}`),
+				response(`{"error":[],"result":{"count":0,"closed":{}}}`),
+				response(string(addOrderJSON)),
+			},
+			methods: []string{"OpenOrders", "ClosedOrders", "AddOrder"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpSequenceMock{Responses: tt.responses}
+			api := &Kraken{
+				secret: deadbeaf,
+				client: mock,
+			}
+			got, err := api.AddOrderIdempotent(clOrdID, "XXBTZUSD", SideBuy, OrderTypeLimit, 1.25, map[string]interface{}{
+				"price": "27500.0",
+			})
+			if err != nil {
+				t.Errorf("Kraken.AddOrderIdempotent() error = %v", err)
+				return
+			}
+			assert.Equal(t, []string{"OUF4EM-FRGI2-MQMWZD"}, got.TransactionIds)
+			assert.Equal(t, "buy 1.25000000 XBTUSD @ limit 27500.0", got.Description.Info)
+			if !assert.Len(t, mock.Requests, len(tt.methods)) {
+				return
+			}
+			for i, method := range tt.methods {
+				assert.True(t, strings.HasSuffix(mock.Requests[i].URL.Path, "/"+method))
+				assert.Equal(t, clOrdID, requestForm(t, mock.Requests[i]).Get("cl_ord_id"))
+			}
+		})
+	}
+}
+
+func TestKraken_SafeAddOrder(t *testing.T) {
+	clOrdID := "6d1b345e-2821-40e2-ad83-4ecb18a06876"
+	ok := func(body string) *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+	validated := `{"error":[],"result":{"descr":{"order":"buy 1.25000000 XBTUSD @ limit 27500.0"}}}`
+	placed := `{"OUF4EM-FRGI2-MQMWZD":{"refid":null,"userref":null,"cl_ord_id":"` + clOrdID + `","status":"open","opentm":1616666559.8974,"starttm":0,"expiretm":0,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"limit","price":"27500.0","price2":"0","leverage":"none","order":"buy 1.25000000 XBTUSD @ limit 27500.0","close":""},"vol":"1.25000000","vol_exec":"0.00000000","cost":"0.00000","fee":"0.00000","price":"0.00000","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}}`
+	tests := []struct {
+		name      string
+		window    time.Duration
+		responses []*http.Response
+		errors    []error
+		methods   []string
+		wantErr   bool
+	}{
+		{
+			name:      "Placed at first attempt",
+			window:    minOrderDeadline,
+			responses: []*http.Response{ok(validated), ok(string(addOrderJSON))},
+			methods:   []string{"AddOrder", "AddOrder"},
+		}, {
+			name:      "Timeout but order was placed",
+			window:    minOrderDeadline,
+			responses: []*http.Response{ok(validated), nil, ok(`{"error":[],"result":{"open":` + placed + `}}`)},
+			errors:    []error{nil, context.DeadlineExceeded},
+			methods:   []string{"AddOrder", "AddOrder", "OpenOrders"},
+		}, {
+			name:   "Timeout and order was not placed",
+			window: minOrderDeadline,
+			responses: []*http.Response{
+				ok(validated),
+				nil,
+				ok(`{"error":[],"result":{"open":{}}}`),
+				ok(`{"error":[],"result":{"count":0,"closed":{}}}`),
+				ok(string(addOrderJSON)),
+			},
+			errors:  []error{nil, context.DeadlineExceeded},
+			methods: []string{"AddOrder", "AddOrder", "OpenOrders", "ClosedOrders", "AddOrder"},
+		}, {
+			name:      "Validation failed",
+			window:    minOrderDeadline,
+			responses: []*http.Response{ok(`{"error":["EOrder:Insufficient funds"]}`)},
+			methods:   []string{"AddOrder"},
+			wantErr:   true,
+		}, {
+			name:    "Window too short",
+			window:  time.Second,
+			wantErr: true,
+		}, {
+			name:    "Window too long",
+			window:  maxOrderDeadline + time.Second,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpSequenceMock{Responses: tt.responses, Errors: tt.errors}
+			api := &Kraken{
+				secret: deadbeaf,
+				client: mock,
+			}
+			got, err := api.SafeAddOrder(context.Background(), clOrdID, "XXBTZUSD", SideBuy, OrderTypeLimit, 1.25, map[string]interface{}{
+				"price": "27500.0",
+			}, tt.window)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Kraken.SafeAddOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, []string{"OUF4EM-FRGI2-MQMWZD"}, got.TransactionIds)
+			}
+			if !assert.Len(t, mock.Requests, len(tt.methods)) {
+				return
+			}
+			for i, method := range tt.methods {
+				assert.True(t, strings.HasSuffix(mock.Requests[i].URL.Path, "/"+method))
+				form := requestForm(t, mock.Requests[i])
+				assert.Equal(t, clOrdID, form.Get("cl_ord_id"))
+				if method == "AddOrder" {
+					assert.Equal(t, i == 0, form.Get("validate") == "true")
+					assert.Equal(t, i != 0, form.Get("deadline") != "")
+				}
+			}
+		})
+	}
+}
+
+func TestKraken_AddOrderValidatesTypes(t *testing.T) {
+	tests := []struct {
+		name      string
+		side      string
+		orderType string
+		wantErr   bool
+	}{
+		{
+			name:      "Valid side and order type",
+			side:      SideSell,
+			orderType: OrderTypeStopLossLimit,
+			wantErr:   false,
+		}, {
+			name:      "Unknown order type",
+			side:      SideBuy,
+			orderType: "iceberg",
+			wantErr:   true,
+		}, {
+			name:      "Unknown side",
+			side:      "hold",
+			orderType: OrderTypeMarket,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpMock{
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader(addOrderJSON)),
+				},
+			}
+			api := &Kraken{
+				secret: deadbeaf,
+				client: mock,
+			}
+			_, err := api.AddOrder("XXBTZUSD", tt.side, tt.orderType, 1.25, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Kraken.AddOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Nil(t, mock.Request)
+			}
+		})
+	}
+}
+
+func TestKraken_AddOrderSTPType(t *testing.T) {
+	tests := []struct {
+		name    string
+		stpType interface{}
+		wantErr bool
+	}{
+		{
+			name:    "Valid stptype",
+			stpType: STPCancelBoth,
+			wantErr: false,
+		}, {
+			name:    "Invalid stptype",
+			stpType: "cancel-all",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpMock{
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader(addOrderJSON)),
+				},
+			}
+			api := &Kraken{
+				secret: deadbeaf,
+				client: mock,
+			}
+			_, err := api.AddOrder("XXBTZUSD", Buy, OTLimit, 1.25, map[string]interface{}{
+				ArgSTPType: tt.stpType,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Kraken.AddOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Equal(t, (*http.Request)(nil), mock.Request)
+			} else {
+				assert.Equal(t, tt.stpType, requestForm(t, mock.Request).Get(ArgSTPType))
+			}
+		})
+	}
+}
+
+func TestKraken_AddOrderFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Valid typed flags",
+			flags:   []OrderFlag{OFlagPostOnly, OFlagFeeInQuote},
+			want:    "post,fciq",
+			wantErr: false,
+		}, {
+			name:    "Valid string flags",
+			flags:   "post,fciq",
+			want:    "post,fciq",
+			wantErr: false,
+		}, {
+			name:    "Invalid flag",
+			flags:   []OrderFlag{OFlagPostOnly, "ioc"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &httpMock{
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader(addOrderJSON)),
+				},
+			}
+			api := &Kraken{
+				secret: deadbeaf,
+				client: mock,
+			}
+			_, err := api.AddOrder("XXBTZUSD", Buy, OTLimit, 1.25, map[string]interface{}{
+				ArgOrderFlags: tt.flags,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Kraken.AddOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Equal(t, (*http.Request)(nil), mock.Request)
+			} else {
+				assert.Equal(t, tt.want, requestForm(t, mock.Request).Get(ArgOrderFlags))
+			}
+		})
+	}
+}
+
+func TestKraken_AddOrderTrailingStop(t *testing.T) {
+	mock := &httpMock{
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewReader(addTrailingStopOrderJSON)),
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+	}
+	got, err := api.AddOrder("XXBTZUSD", Sell, OTTrailingStop, 1.25, map[string]interface{}{
+		"price": "+5%",
+	})
+	if err != nil {
+		t.Errorf("Kraken.AddOrder() error = %v", err)
+		return
+	}
+	form := requestForm(t, mock.Request)
+	assert.Equal(t, OTTrailingStop, form.Get("ordertype"))
+	assert.Equal(t, "+5%", form.Get("price"))
+
+	assert.Equal(t, OTTrailingStop, got.Description.OrderType)
+	assert.Equal(t, "+5.0000%", got.Description.RelativePrice)
+	assert.Equal(t, 0.0, got.Description.Price)
+	assert.Equal(t, "", got.Description.RelativePrice2)
+}
+
+func TestKraken_WebSocketToken_context(t *testing.T) {
+	api := &Kraken{
+		secret:  deadbeaf,
+		client:  &httpBlockingMock{},
+		token:   &tokenCache{},
+		timeout: 10 * time.Millisecond,
+	}
+	_, err := api.WebSocketToken(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	api = api.WithContext(ctx)
+	api.timeout = time.Minute
+	_, err = api.WebSocketToken(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestKraken_WebSocketToken(t *testing.T) {
+	now := time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC)
+	mock := &httpSequenceMock{
+		Responses: []*http.Response{
+			{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewReader(getWSTokenJSON)),
+			}, {
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"error":[],"result":{"token": "refreshed", "expires": 900}}`)),
+			},
+		},
+	}
+	api := &Kraken{
+		secret: deadbeaf,
+		client: mock,
+		token: &tokenCache{
+			now: func() time.Time { return now },
+		},
+	}
+	api.SetTokenRefreshMargin(time.Minute)
+
+	token, err := api.WebSocketToken(context.Background())
+	if err != nil {
+		t.Errorf("Kraken.WebSocketToken() error = %v", err)
+		return
+	}
+	assert.Equal(t, "test", token)
+
+	now = now.Add(10 * time.Minute)
+	token, err = api.WebSocketToken(context.Background())
+	if err != nil {
+		t.Errorf("Kraken.WebSocketToken() error = %v", err)
+		return
+	}
+	assert.Equal(t, "test", token)
+	assert.Len(t, mock.Requests, 1)
+
+	now = now.Add(4*time.Minute + 30*time.Second)
+	token, err = api.WebSocketToken(context.Background())
+	if err != nil {
+		t.Errorf("Kraken.WebSocketToken() error = %v", err)
+		return
+	}
+	assert.Equal(t, "refreshed", token)
+	assert.Len(t, mock.Requests, 2)
+}
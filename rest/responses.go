@@ -1,10 +1,17 @@
 package rest
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ericlagergren/decimal"
 )
@@ -16,11 +23,76 @@ func getFloat64FromStr(value interface{}) (float64, error) {
 	}
 	f, err := strconv.ParseFloat(str, 64)
 	if err != nil {
-		return .0, err
+		return .0, fmt.Errorf("parsing %q as float: %w", str, err)
 	}
 	return f, nil
 }
 
+// decimalContext - context of decimals parsed from responses, see SetDecimalContext
+var decimalContext struct {
+	mx  sync.RWMutex
+	ctx decimal.Context
+}
+
+// SetDecimalContext - sets context of decimals parsed from responses (OHLC, Ticker, Spread, order prices) and of arithmetic in their helpers.
+// Zero value (default) keeps all parsed digits and uses decimal.DefaultPrecision for arithmetic.
+// It's safe to call concurrently with decoding, but it must be set before the first request, e.g. `rest.SetDecimalContext(decimal.Context128)`,
+// otherwise decimals decoded before and after the call have different contexts.
+func SetDecimalContext(ctx decimal.Context) {
+	decimalContext.mx.Lock()
+	decimalContext.ctx = ctx
+	decimalContext.mx.Unlock()
+}
+
+// DecimalContext - returns context of decimals parsed from responses, see SetDecimalContext
+func DecimalContext() decimal.Context {
+	decimalContext.mx.RLock()
+	defer decimalContext.mx.RUnlock()
+	return decimalContext.ctx
+}
+
+func newDecimal() *decimal.Big {
+	return decimal.WithContext(DecimalContext())
+}
+
+// parseDecimal - parses a numeric string into decimal with DecimalContext. Plain and scientific notation (e.g. `1e-08`) are accepted.
+func parseDecimal(str string) (*decimal.Big, error) {
+	ctx := DecimalContext()
+	d, ok := decimal.WithContext(ctx).SetString(strings.ToLower(strings.TrimSpace(str)))
+	if !ok || d.IsNaN(0) || d.IsInf(0) {
+		return nil, fmt.Errorf("parsing %q as decimal: invalid syntax", str)
+	}
+	if ctx.Precision != 0 {
+		ctx.Round(d)
+	}
+	return d, nil
+}
+
+func getDecimalFromStr(value interface{}) (*decimal.Big, error) {
+	str, ok := value.(string)
+	if !ok {
+		return nil, errors.New("field must be a string")
+	}
+	return parseDecimal(str)
+}
+
+// decodeDecimals - decodes JSON array of numeric strings or numbers into decimals
+func decodeDecimals(buf []byte) ([]*decimal.Big, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(buf, &raw); err != nil {
+		return nil, err
+	}
+	result := make([]*decimal.Big, len(raw))
+	for i := range raw {
+		d, err := parseDecimal(strings.Trim(string(raw[i]), `"`))
+		if err != nil {
+			return nil, err
+		}
+		result[i] = d
+	}
+	return result, nil
+}
+
 func getFloat64(value interface{}) (float64, error) {
 	f, ok := value.(float64)
 	if !ok {
@@ -29,18 +101,74 @@ func getFloat64(value interface{}) (float64, error) {
 	return f, nil
 }
 
+// getTimestamp - returns integer part of numeric field. Values decoded with UseNumber are converted without float64 rounding, so integers above 2^53 are preserved.
 func getTimestamp(value interface{}) (int64, error) {
-	f, ok := value.(float64)
-	if !ok {
-		return 0, errors.New("field must be a float64")
+	switch v := value.(type) {
+	case float64:
+		return int64(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, err
+		}
+		return int64(f), nil
+	}
+	return 0, errors.New("field must be a number")
+}
+
+// unmarshalUseNumber - decodes JSON keeping numbers as json.Number
+func unmarshalUseNumber(buf []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// Cursor - `since` and `last` cursor of incremental public endpoints. Its unit depends on endpoint:
+// Trades cursor is a unix timestamp in nanoseconds (see TradesCursor), Spread cursor is a unix timestamp in seconds (see SpreadCursor).
+// It's decoded from JSON string or number without float64 precision loss.
+type Cursor int64
+
+// TradesCursor - returns Trades cursor of time `t`
+func TradesCursor(t time.Time) Cursor {
+	return Cursor(t.UnixNano())
+}
+
+// SpreadCursor - returns Spread cursor of time `t`
+func SpreadCursor(t time.Time) Cursor {
+	return Cursor(t.Unix())
+}
+
+// String - formats cursor as Kraken expects in `since` parameter
+func (c Cursor) String() string {
+	return strconv.FormatInt(int64(c), 10)
+}
+
+// UnmarshalJSON -
+func (c *Cursor) UnmarshalJSON(buf []byte) error {
+	value, err := strconv.ParseInt(strings.Trim(string(buf), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid cursor %s: %w", buf, err)
 	}
-	return int64(f), nil
+	*c = Cursor(value)
+	return nil
 }
 
 // KrakenResponse - template of Kraken API response
 type KrakenResponse struct {
-	Error  []string    `json:"error"`
-	Result interface{} `json:"result"`
+	Error   []string    `json:"error"`
+	Result  interface{} `json:"result"`
+	Warning []string    `json:"warning,omitempty"`
+}
+
+// Marshal - encodes response envelope as Kraken does: empty error list is encoded as `[]`, not `null`
+func (r KrakenResponse) Marshal() ([]byte, error) {
+	if r.Error == nil {
+		r.Error = []string{}
+	}
+	return json.Marshal(r)
 }
 
 // TimeResponse - Result of Time request
@@ -49,6 +177,25 @@ type TimeResponse struct {
 	Rfc1123  string `json:"rfc1123"`
 }
 
+// rfc1123Layout - layout of time sent by Kraken: two-digit year and not padded day (e.g. `Tue,  2 Apr 19 15:15:08 +0000`)
+const rfc1123Layout = "Mon, 2 Jan 06 15:04:05 -0700"
+
+// ParsedRFC1123 - parses `Rfc1123` field and returns time in UTC. Repeated spaces (e.g. after weekday) are ignored.
+func (t TimeResponse) ParsedRFC1123() (time.Time, error) {
+	value := strings.Join(strings.Fields(t.Rfc1123), " ")
+	parsed, err := time.Parse(rfc1123Layout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing %q as RFC1123 time: %w", t.Rfc1123, err)
+	}
+	return parsed.UTC(), nil
+}
+
+// SystemStatusResponse - Result of SystemStatus request
+type SystemStatusResponse struct {
+	Status    string `json:"status"` // online, maintenance, cancel_only, post_only
+	Timestamp string `json:"timestamp"`
+}
+
 // Asset - asset information
 type Asset struct {
 	AlternateName   string `json:"altname"`
@@ -85,6 +232,67 @@ type AssetPair struct {
 	ShortPositionLimit int          `json:"short_position_limit"`
 }
 
+// InitialMargin - returns fraction of position cost required as initial margin at `leverage` (e.g. 0.2 for 5:1). Returns nil if leverage is not positive.
+func (item AssetPair) InitialMargin(leverage int) *decimal.Big {
+	if leverage <= 0 {
+		return nil
+	}
+	return newDecimal().Quo(decimal.New(1, 0), decimal.New(int64(leverage), 0))
+}
+
+// MarginCallLevel - returns margin level in percents at which margin call is triggered. It's comparable with TradeBalanceResponse.MarginLevel.
+func (item AssetPair) MarginCallLevel() *decimal.Big {
+	return newDecimal().SetMantScale(int64(item.MarginCall), 0)
+}
+
+// MarginStopLevel - returns margin level in percents at which positions are liquidated. It's comparable with TradeBalanceResponse.MarginLevel.
+func (item AssetPair) MarginStopLevel() *decimal.Big {
+	return newDecimal().SetMantScale(int64(item.MarginStop), 0)
+}
+
+// FeeTier - fee of 30-day volume tier
+type FeeTier struct {
+	Volume  *decimal.Big // minimal 30-day volume of tier in `fee_volume_currency`
+	Percent *decimal.Big // fee in percents
+}
+
+func newFeeTiers(fees [][]float64) []FeeTier {
+	tiers := make([]FeeTier, 0, len(fees))
+	for _, fee := range fees {
+		if len(fee) < 2 {
+			continue
+		}
+		tiers = append(tiers, FeeTier{
+			Volume:  decimalFromFloat64(fee[0]),
+			Percent: decimalFromFloat64(fee[1]),
+		})
+	}
+	return tiers
+}
+
+// FeeTiers - returns taker fee schedule
+func (item AssetPair) FeeTiers() []FeeTier {
+	return newFeeTiers(item.Fees)
+}
+
+// FeeMakerTiers - returns maker fee schedule
+func (item AssetPair) FeeMakerTiers() []FeeTier {
+	return newFeeTiers(item.FeesMaker)
+}
+
+// FeeAt - returns taker fee in percents of tier applicable at 30-day `volume`. Returns nil if fee schedule is unknown.
+func (item AssetPair) FeeAt(volume float64) *decimal.Big {
+	var fee *decimal.Big
+	v := decimalFromFloat64(volume)
+	for _, tier := range item.FeeTiers() {
+		if tier.Volume.Cmp(v) > 0 {
+			break
+		}
+		fee = tier.Percent
+	}
+	return fee
+}
+
 // Level - ticker structure for Ask and Bid
 type Level struct {
 	Price          *decimal.Big
@@ -94,8 +302,8 @@ type Level struct {
 
 // UnmarshalJSON -
 func (item *Level) UnmarshalJSON(buf []byte) error {
-	var tmp []*decimal.Big
-	if err := json.Unmarshal(buf, &tmp); err != nil {
+	tmp, err := decodeDecimals(buf)
+	if err != nil {
 		return err
 	}
 	if g, e := len(tmp), 3; g != e {
@@ -136,8 +344,8 @@ type CloseLevel struct {
 
 // UnmarshalJSON -
 func (item *CloseLevel) UnmarshalJSON(buf []byte) error {
-	var tmp []*decimal.Big
-	if err := json.Unmarshal(buf, &tmp); err != nil {
+	tmp, err := decodeDecimals(buf)
+	if err != nil {
 		return err
 	}
 	if g, e := len(tmp), 2; g != e {
@@ -163,6 +371,45 @@ type Ticker struct {
 	OpeningPrice       *decimal.Big
 }
 
+// UnmarshalJSON - decodes ticker arrays and scalar opening price `o`
+func (item *Ticker) UnmarshalJSON(buf []byte) error {
+	type alias Ticker
+	tmp := struct {
+		*alias
+		OpeningPrice *string `json:"o"`
+	}{
+		alias: (*alias)(item),
+	}
+	if err := json.Unmarshal(buf, &tmp); err != nil {
+		return err
+	}
+	item.OpeningPrice = nil
+	if tmp.OpeningPrice != nil {
+		price, err := parseDecimal(*tmp.OpeningPrice)
+		if err != nil {
+			return fmt.Errorf("invalid Ticker opening price: %w", err)
+		}
+		item.OpeningPrice = price
+	}
+	return nil
+}
+
+// ChangePct - returns change of last trade price relative to today's opening price in percents.
+// Returns nil if opening or last price is unknown or opening price is zero.
+func (item Ticker) ChangePct() *decimal.Big {
+	if item.OpeningPrice == nil || item.Close.Price == nil || item.OpeningPrice.Sign() == 0 {
+		return nil
+	}
+	change := newDecimal().Sub(item.Close.Price, item.OpeningPrice)
+	change.Quo(change, item.OpeningPrice)
+	return change.Mul(change, decimal.New(100, 0))
+}
+
+// DayRange - returns today's low and high prices
+func (item Ticker) DayRange() (low, high *decimal.Big) {
+	return item.Low.Price, item.High.Price
+}
+
 // Candle - OHLC item
 type Candle struct {
 	Time      int64
@@ -175,16 +422,37 @@ type Candle struct {
 	Count     int64
 }
 
+// Range - returns difference between high and low prices of candle
+func (c Candle) Range() *decimal.Big {
+	return newDecimal().Sub(c.High, c.Low)
+}
+
+// Body - returns absolute difference between close and open prices of candle
+func (c Candle) Body() *decimal.Big {
+	body := newDecimal().Sub(c.Close, c.Open)
+	return body.Abs(body)
+}
+
+// IsBullish - returns true if candle closed higher than it opened
+func (c Candle) IsBullish() bool {
+	return c.Close.Cmp(c.Open) > 0
+}
+
 // OHLCResponse - response of OHLC request
 type OHLCResponse struct {
 	Candles map[string][]Candle `json:"-"`
 	Last    int64               `json:"last"`
 }
 
+// Count - returns number of candles of `pair` in response. Returns 0 if pair is absent.
+func (item OHLCResponse) Count(pair string) int {
+	return len(item.Candles[pair])
+}
+
 // UnmarshalJSON -
 func (item *OHLCResponse) UnmarshalJSON(buf []byte) error {
 	res := make(map[string]interface{})
-	if err := json.Unmarshal(buf, &res); err != nil {
+	if err := unmarshalUseNumber(buf, &res); err != nil {
 		return err
 	}
 
@@ -197,50 +465,45 @@ func (item *OHLCResponse) UnmarshalJSON(buf []byte) error {
 
 	item.Candles = make(map[string][]Candle)
 	for k, v := range res {
-		items := v.([]interface{})
+		// pair without data (e.g. freshly listed) has null value
+		items, ok := v.([]interface{})
+		if !ok && v != nil {
+			return fmt.Errorf("invalid OHLC candles of %s", k)
+		}
 		item.Candles[k] = make([]Candle, len(items))
 		for idx, c := range items {
-			candle := c.([]interface{})
-
-			ts, err2 := getTimestamp(candle[0])
-			if err2 != nil {
+			candle, ok := c.([]interface{})
+			if !ok || len(candle) < 8 {
 				continue
 			}
-			var open, high, low, close2, vwap, vol decimal.Big
 
-			err = open.UnmarshalText([]byte(candle[1].(string)))
-			if err != nil {
-				continue
-			}
-			err = high.UnmarshalText([]byte(candle[2].(string)))
-			if err != nil {
-				continue
-			}
-			err = low.UnmarshalText([]byte(candle[3].(string)))
-			if err != nil {
+			ts, err2 := getTimestamp(candle[0])
+			if err2 != nil {
 				continue
 			}
-			err = close2.UnmarshalText([]byte(candle[4].(string)))
-			if err != nil {
-				continue
+			values := make([]*decimal.Big, 6)
+			for i := range values {
+				values[i], err = getDecimalFromStr(candle[i+1])
+				if err != nil {
+					break
+				}
 			}
-			err = vwap.UnmarshalText([]byte(candle[5].(string)))
 			if err != nil {
 				continue
 			}
-			err = vol.UnmarshalText([]byte(candle[6].(string)))
-			if err != nil {
+			count, err2 := getTimestamp(candle[7])
+			if err2 != nil {
 				continue
 			}
 			item.Candles[k][idx] = Candle{
 				Time:      ts,
-				Open:      &open,
-				High:      &high,
-				Low:       &low,
-				Close:     &close2,
-				VolumeWAP: &vwap,
-				Volume:    &vol,
-				Count:     int64(candle[7].(float64)),
+				Open:      values[0],
+				High:      values[1],
+				Low:       values[2],
+				Close:     values[3],
+				VolumeWAP: values[4],
+				Volume:    values[5],
+				Count:     count,
 			}
 		}
 	}
@@ -252,12 +515,53 @@ type OrderBookItem struct {
 	Price     float64
 	Volume    float64
 	Timestamp int64
+	// RawPrice and RawVolume - price and volume exactly as sent by Kraken
+	RawPrice  string `json:"-"`
+	RawVolume string `json:"-"`
+	// RawTimestamp - timestamp exactly as sent by Kraken. Unlike Timestamp it keeps sub-second part.
+	RawTimestamp string `json:"-"`
+}
+
+// Time - returns level update time with sub-second precision if Kraken sent it
+func (item OrderBookItem) Time() time.Time {
+	if t, err := parseUnixTime(item.RawTimestamp); err == nil {
+		return t
+	}
+	return time.Unix(item.Timestamp, 0)
+}
+
+// parseUnixTime - parses unix timestamp in seconds with optional fractional part without float64 precision loss
+func parseUnixTime(str string) (time.Time, error) {
+	if strings.ContainsAny(str, "eE") {
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+	secPart, fracPart, _ := strings.Cut(str, ".")
+	sec, err := strconv.ParseInt(secPart, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(fracPart) > 9 {
+		fracPart = fracPart[:9]
+	}
+	var nsec int64
+	if fracPart != "" {
+		nsec, err = strconv.ParseInt(fracPart+strings.Repeat("0", 9-len(fracPart)), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(sec, nsec), nil
 }
 
 // UnmarshalJSON -
 func (item *OrderBookItem) UnmarshalJSON(buf []byte) error {
 	var tmp []interface{}
-	if err := json.Unmarshal(buf, &tmp); err != nil {
+	if err := unmarshalUseNumber(buf, &tmp); err != nil {
 		return err
 	}
 	if g, e := len(tmp), 3; g != e {
@@ -266,21 +570,24 @@ func (item *OrderBookItem) UnmarshalJSON(buf []byte) error {
 
 	price, err := getFloat64FromStr(tmp[0])
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid OrderBookItem price: %w", err)
 	}
 	item.Price = price
+	item.RawPrice = tmp[0].(string)
 
 	vol, err := getFloat64FromStr(tmp[1])
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid OrderBookItem volume: %w", err)
 	}
 	item.Volume = vol
+	item.RawVolume = tmp[1].(string)
 
 	ts, err := getTimestamp(tmp[2])
 	if err != nil {
 		return err
 	}
 	item.Timestamp = ts
+	item.RawTimestamp = tmp[2].(json.Number).String()
 
 	return nil
 }
@@ -291,6 +598,27 @@ type OrderBook struct {
 	Bids []OrderBookItem `json:"bids"`
 }
 
+// checksumDepth - count of best levels of each side included into order book checksum
+const checksumDepth = 10
+
+// Checksum - computes CRC32 checksum of top 10 asks and bids as Kraken does for websocket book.
+// Raw price and volume strings are used, so it matches websocket checksum only if REST and websocket precisions of pair are the same.
+// Details https://docs.kraken.com/websockets/#book-checksum
+func (b OrderBook) Checksum() uint32 {
+	var str strings.Builder
+	for _, side := range [][]OrderBookItem{b.Asks, b.Bids} {
+		for i := 0; i < checksumDepth && i < len(side); i++ {
+			str.WriteString(checksumValue(side[i].RawPrice))
+			str.WriteString(checksumValue(side[i].RawVolume))
+		}
+	}
+	return crc32.ChecksumIEEE([]byte(str.String()))
+}
+
+func checksumValue(value string) string {
+	return strings.TrimLeft(strings.Replace(value, ".", "", 1), "0")
+}
+
 // Trade - structure of public trades
 type Trade struct {
 	Price     float64
@@ -314,13 +642,13 @@ func (item *Trade) UnmarshalJSON(buf []byte) error {
 
 	price, err := getFloat64FromStr(tmp[0])
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid Trade price: %w", err)
 	}
 	item.Price = price
 
 	vol, err := getFloat64FromStr(tmp[1])
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid Trade volume: %w", err)
 	}
 	item.Volume = vol
 
@@ -355,28 +683,105 @@ func (item *Trade) UnmarshalJSON(buf []byte) error {
 
 type Trades []Trade
 
-// TradeResponse allows for the return of pairs that have not yet been defined
+func decimalFromFloat64(f float64) *decimal.Big {
+	d := newDecimal()
+	d.SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return d
+}
+
+// AggregateTradesToCandles - builds OHLCV candles of `interval` from trades. Candle time is the start of its interval.
+// Intervals without trades are skipped, so returned candles are not necessarily contiguous.
+func AggregateTradesToCandles(trades []Trade, interval time.Duration) []Candle {
+	if len(trades) == 0 || interval <= 0 {
+		return nil
+	}
+
+	sorted := make([]Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time < sorted[j].Time })
+
+	step := interval.Seconds()
+	candles := make([]Candle, 0)
+	var notional *decimal.Big
+	for _, trade := range sorted {
+		start := int64(math.Floor(trade.Time/step) * step)
+		price := decimalFromFloat64(trade.Price)
+		volume := decimalFromFloat64(trade.Volume)
+
+		last := len(candles) - 1
+		if last < 0 || candles[last].Time != start {
+			candles = append(candles, Candle{
+				Time:      start,
+				Open:      price,
+				High:      new(decimal.Big).Copy(price),
+				Low:       new(decimal.Big).Copy(price),
+				Close:     new(decimal.Big).Copy(price),
+				VolumeWAP: new(decimal.Big).Copy(price),
+				Volume:    volume,
+				Count:     1,
+			})
+			notional = new(decimal.Big).Mul(price, volume)
+			continue
+		}
+
+		candle := &candles[last]
+		if price.Cmp(candle.High) > 0 {
+			candle.High.Copy(price)
+		}
+		if price.Cmp(candle.Low) < 0 {
+			candle.Low.Copy(price)
+		}
+		candle.Close.Copy(price)
+		candle.Volume.Add(candle.Volume, volume)
+		candle.Count++
+		notional.Add(notional, new(decimal.Big).Mul(price, volume))
+		if candle.Volume.Sign() != 0 {
+			candle.VolumeWAP.Quo(notional, candle.Volume)
+		}
+	}
+	return candles
+}
+
+// TradeResponse allows for the return of pairs that have not yet been defined. `Last` is a cursor of the next Trades request.
 type TradeResponse struct {
 	Key    string `json:"key"`
-	Last   string `json:"last"`
+	Last   Cursor `json:"last"`
 	Trades `json:"trades"`
 }
 
+// Count - returns number of trades in response
+func (t TradeResponse) Count() int {
+	return len(t.Trades)
+}
+
 func (t *TradeResponse) UnmarshalJSON(data []byte) error {
 	if string(data) == "null" || string(data) == `""` {
 		return nil
 	}
 	m := make(map[string]interface{})
-	err := json.Unmarshal(data, &m)
+	err := unmarshalUseNumber(data, &m)
 	if err != nil {
 		return err
 	}
 	for k, v := range m {
 		if k == "last" {
-			t.Last = v.(string)
+			switch last := v.(type) {
+			case string:
+				err = t.Last.UnmarshalJSON([]byte(last))
+			case json.Number:
+				err = t.Last.UnmarshalJSON([]byte(last))
+			}
+			if err != nil {
+				return err
+			}
 		} else {
 			t.Key = k
-			items := v.([]interface{})
+			// pair without data (e.g. freshly listed) has null value
+			items, ok := v.([]interface{})
+			if !ok && v != nil {
+				return fmt.Errorf("invalid trades of %s", k)
+			}
+			t.Trades = make(Trades, 0, len(items))
 			for _, item := range items {
 				bytes, err2 := json.Marshal(item)
 				if err2 != nil {
@@ -419,21 +824,61 @@ func (item *Spread) UnmarshalJSON(buf []byte) error {
 
 	bid, err := getFloat64FromStr(tmp[1])
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid Spread bid: %w", err)
 	}
 	item.Bid = bid
 
 	ask, err := getFloat64FromStr(tmp[2])
 	if err != nil {
+		return fmt.Errorf("invalid Spread ask: %w", err)
+	}
+	item.Ask = ask
+	return nil
+}
+
+// SpreadDecimal - spread item with exact bid and ask prices
+type SpreadDecimal struct {
+	Time int64
+	Bid  *decimal.Big
+	Ask  *decimal.Big
+}
+
+// UnmarshalJSON -
+func (item *SpreadDecimal) UnmarshalJSON(buf []byte) error {
+	var tmp []interface{}
+	if err := json.Unmarshal(buf, &tmp); err != nil {
 		return err
 	}
+	if g, e := len(tmp), 3; g != e {
+		return fmt.Errorf("wrong number of fields in SpreadDecimal: %d != %d", g, e)
+	}
+
+	ts, err := getTimestamp(tmp[0])
+	if err != nil {
+		return err
+	}
+	item.Time = ts
+
+	bid, err := getDecimalFromStr(tmp[1])
+	if err != nil {
+		return fmt.Errorf("invalid Spread bid: %w", err)
+	}
+	item.Bid = bid
+
+	ask, err := getDecimalFromStr(tmp[2])
+	if err != nil {
+		return fmt.Errorf("invalid Spread ask: %w", err)
+	}
 	item.Ask = ask
 	return nil
 }
 
-// SpreadResponse - response of spread request
+// SpreadResponse - response of spread request. Spreads of any pair are available in `Pairs` keyed by pair name returned by Kraken.
 type SpreadResponse struct {
-	Last     float64 `json:"last"`
+	Last  Cursor              `json:"last"`
+	Pairs map[string][]Spread `json:"-"`
+
+	// Deprecated: fields below cover only a fixed set of pairs. They are still populated for compatibility, use Pair or Pairs instead.
 	ADACAD   []Spread
 	ADAETH   []Spread
 	ADAEUR   []Spread
@@ -507,6 +952,123 @@ type SpreadResponse struct {
 	XZECZUSD []Spread
 }
 
+// UnmarshalJSON - decodes spreads of all pairs into `Pairs` as well as into fixed pair fields
+func (item *SpreadResponse) UnmarshalJSON(buf []byte) error {
+	type fixed SpreadResponse
+	var tmp fixed
+	if err := json.Unmarshal(buf, &tmp); err != nil {
+		return err
+	}
+
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(buf, &raw); err != nil {
+		return err
+	}
+	tmp.Pairs = make(map[string][]Spread, len(raw))
+	for key, value := range raw {
+		if key == "last" {
+			continue
+		}
+		var spreads []Spread
+		if err := json.Unmarshal(value, &spreads); err != nil {
+			return err
+		}
+		tmp.Pairs[key] = spreads
+	}
+
+	*item = SpreadResponse(tmp)
+	return nil
+}
+
+// Pair - returns spreads of pair `p`. It replaces access to fixed pair fields, e.g. `resp.Pair("XXBTZUSD")` instead of `resp.XXBTZUSD`.
+func (item SpreadResponse) Pair(p string) []Spread {
+	return item.Pairs[p]
+}
+
+// ExtendedBalance - response item on BalanceEx request
+type ExtendedBalance struct {
+	Balance   *decimal.Big `json:"balance"`
+	HoldTrade *decimal.Big `json:"hold_trade"`
+}
+
+// EarnStrategy - structure of earn strategy
+type EarnStrategy struct {
+	ID                string       `json:"id"`
+	Asset             string       `json:"asset"`
+	LockType          EarnLockType `json:"lock_type"`
+	APREstimate       *EarnAPR     `json:"apr_estimate,omitempty"`
+	UserMinAllocation *decimal.Big `json:"user_min_allocation"`
+	UserCap           *decimal.Big `json:"user_cap"`
+	AllocationFee     *decimal.Big `json:"allocation_fee"`
+	DeallocationFee   *decimal.Big `json:"deallocation_fee"`
+	CanAllocate       bool         `json:"can_allocate"`
+	CanDeallocate     bool         `json:"can_deallocate"`
+}
+
+// EarnLockType - lock parameters of earn strategy. Periods are in seconds.
+type EarnLockType struct {
+	Type            string `json:"type"`
+	PayoutFrequency int64  `json:"payout_frequency"`
+	BondingPeriod   int64  `json:"bonding_period"`
+	UnbondingPeriod int64  `json:"unbonding_period"`
+}
+
+// EarnAPR - estimated annual percentage rate range of earn strategy
+type EarnAPR struct {
+	Low  *decimal.Big `json:"low"`
+	High *decimal.Big `json:"high"`
+}
+
+// EarnStrategiesResponse - response on Earn/Strategies request
+type EarnStrategiesResponse struct {
+	Items      []EarnStrategy `json:"items"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+// EarnAmount - amount in native asset and converted to requested currency
+type EarnAmount struct {
+	Native    *decimal.Big `json:"native"`
+	Converted *decimal.Big `json:"converted"`
+}
+
+// EarnAllocatedAmounts - allocated amounts by state
+type EarnAllocatedAmounts struct {
+	Bonding   *EarnAmount `json:"bonding,omitempty"`
+	ExitQueue *EarnAmount `json:"exit_queue,omitempty"`
+	Pending   *EarnAmount `json:"pending,omitempty"`
+	Unbonding *EarnAmount `json:"unbonding,omitempty"`
+	Total     EarnAmount  `json:"total"`
+}
+
+// EarnAllocation - structure of allocation to earn strategy
+type EarnAllocation struct {
+	StrategyID      string               `json:"strategy_id"`
+	NativeAsset     string               `json:"native_asset"`
+	AmountAllocated EarnAllocatedAmounts `json:"amount_allocated"`
+	TotalRewarded   EarnAmount           `json:"total_rewarded"`
+}
+
+// EarnAllocationsResponse - response on Earn/Allocations request
+type EarnAllocationsResponse struct {
+	ConvertedAsset string           `json:"converted_asset"`
+	TotalAllocated *decimal.Big     `json:"total_allocated"`
+	TotalRewarded  *decimal.Big     `json:"total_rewarded"`
+	Items          []EarnAllocation `json:"items"`
+}
+
+// EarnOperationStatus - status of asynchronous allocation or deallocation
+type EarnOperationStatus struct {
+	Pending bool `json:"pending"`
+}
+
+// ConsolidatedAsset - consolidated balance of asset across spot, staking and earn
+type ConsolidatedAsset struct {
+	Spot   *decimal.Big
+	Staked *decimal.Big
+	Earn   *decimal.Big
+	Total  *decimal.Big
+}
+
 // TradeBalanceResponse - response of get trade balance request
 type TradeBalanceResponse struct {
 	EquivalentBalance float64 `json:"eb,string"`
@@ -520,6 +1082,19 @@ type TradeBalanceResponse struct {
 	MarginLevel       float64 `json:"ml,string"`
 }
 
+// IsMarginCall - returns true if there are open margin positions and margin level (in percents) is at or below `callLevel`
+func (t TradeBalanceResponse) IsMarginCall(callLevel float64) bool {
+	return t.OpenMargin > 0 && t.MarginLevel <= callLevel
+}
+
+// UsedMarginPct - returns used margin as percent of equity
+func (t TradeBalanceResponse) UsedMarginPct() float64 {
+	if t.Equity == 0 {
+		return 0
+	}
+	return t.OpenMargin / t.Equity * 100
+}
+
 // OpenOrdersResponse - response on OpenOrders request
 type OpenOrdersResponse struct {
 	Orders map[string]OrderInfo `json:"open"`
@@ -535,6 +1110,7 @@ type ClosedOrdersResponse struct {
 type OrderInfo struct {
 	RefID           *string          `json:"refid"`
 	UserRef         interface{}      `json:"userref"`
+	ClientOrderID   string           `json:"cl_ord_id,omitempty"`
 	Status          string           `json:"status"`
 	Reason          string           `json:"reason,omitempty"`
 	OpenTimestamp   float64          `json:"opentm"`
@@ -546,11 +1122,53 @@ type OrderInfo struct {
 	VolumeExecuted  float64          `json:"vol_exec,string"`
 	Cost            float64          `json:"cost,string"`
 	Fee             float64          `json:"fee,string"`
-	AveragePrice    float64          `json:"price,string"`
-	StopPrice       float64          `json:"stopprice,string"`
-	LimitPrice      float64          `json:"limitprice,string"`
-	Misc            string           `json:"misc"`
-	Flags           string           `json:"oflags"`
+	// AveragePrice - average price of executed volume. Zero until order is filled at least partially.
+	AveragePrice float64 `json:"price,string"`
+	// StopPrice - price at which stop order was triggered. Zero for untriggered and non-stop orders. Requested prices are in Description.
+	StopPrice float64 `json:"stopprice,string"`
+	// LimitPrice - limit price of triggered order with limit (e.g. stop-loss-limit). Zero until order is triggered.
+	LimitPrice float64  `json:"limitprice,string"`
+	Misc       string   `json:"misc"`
+	Flags      string   `json:"oflags"`
+	Trades     []string `json:"trades,omitempty"` // txids of order fills. Returned if trades are requested
+}
+
+// OrderInfoDecimal - volumes and amounts of order with full precision. It mirrors the float64 fields of OrderInfo with the same names and is returned by QueryOrdersDecimal.
+type OrderInfoDecimal struct {
+	Volume         *decimal.Big `json:"vol"`
+	VolumeExecuted *decimal.Big `json:"vol_exec"`
+	Cost           *decimal.Big `json:"cost"`
+	Fee            *decimal.Big `json:"fee"`
+	AveragePrice   *decimal.Big `json:"price"`
+}
+
+// IsPostOnlyReject - returns true if post-only order was canceled by Kraken because it would cross the book
+func (o OrderInfo) IsPostOnlyReject() bool {
+	return o.Status == "canceled" && strings.Contains(strings.ToLower(o.Reason), "post only")
+}
+
+// EffectivePrice - returns average price if order is executed at least partially.
+// Otherwise it returns price order would be executed at: triggered limit price, limit price of `*-limit` orders or primary price (limit, stop or take profit).
+// Returns nil for market orders and orders with relative price (e.g. trailing stops).
+func (o OrderInfo) EffectivePrice() *decimal.Big {
+	if o.VolumeExecuted > 0 && o.AveragePrice > 0 {
+		return decimalFromFloat64(o.AveragePrice)
+	}
+	if o.LimitPrice > 0 {
+		return decimalFromFloat64(o.LimitPrice)
+	}
+	switch o.Description.OrderType {
+	case OTMarket, OTSettlePosition:
+		return nil
+	case OTStopLossLimit, OTTakeProfitLimit:
+		if o.Description.Price2 > 0 {
+			return decimalFromFloat64(o.Description.Price2)
+		}
+	}
+	if o.Description.Price > 0 {
+		return decimalFromFloat64(o.Description.Price)
+	}
+	return nil
 }
 
 // TradesHistoryResponse - respons on TradesHistory request
@@ -581,6 +1199,12 @@ type DepositStatuses struct {
 	Status string `json:"status"`
 }
 
+// DepositStatusPage - response on paginated DepositStatus request
+type DepositStatusPage struct {
+	Deposits   []DepositStatuses `json:"deposit"`
+	NextCursor string            `json:"next_cursor"`
+}
+
 // WithdrawInfo - response on WithdrawInfo request
 type WithdrawInfo struct {
 	Method string `json:"method,omitempty"`
@@ -608,6 +1232,12 @@ type WithdrawStatus struct {
 	Status string `json:"status,omitempty"`
 }
 
+// WithdrawStatusPage - response on paginated WithdrawStatus request
+type WithdrawStatusPage struct {
+	Withdrawals []WithdrawStatus `json:"withdrawals"`
+	NextCursor  string           `json:"next_cursor"`
+}
+
 // PrivateTrade - structure of account's trades
 type PrivateTrade struct {
 	OrderID              string   `json:"ordertxid"`
@@ -654,11 +1284,108 @@ type Position struct {
 	Flags        string  `json:"oflags"`
 }
 
+// PositionSummary - consolidated exposure of open positions on one pair
+type PositionSummary struct {
+	// NetVolume - open volume of long positions minus open volume of short ones
+	NetVolume float64
+	// AveragePrice - volume weighted entry price of positions on the side of net exposure. Zero if positions are netted out.
+	AveragePrice float64
+	Margin       float64
+	// Profit - unrealized profit/loss of all positions. It's calculated by Kraken only if positions are requested with `docalcs`.
+	Profit float64
+}
+
+// AggregatePositions - nets long and short `positions` by pair. Closed part of position volume is excluded.
+func AggregatePositions(positions map[string]Position) map[string]PositionSummary {
+	type sideTotals struct {
+		volume float64
+		cost   float64
+	}
+	longs := make(map[string]*sideTotals)
+	shorts := make(map[string]*sideTotals)
+	summaries := make(map[string]PositionSummary)
+	for _, position := range positions {
+		summary := summaries[position.Pair]
+		summary.Margin += position.Margin
+		summary.Profit += position.Profit
+
+		open := position.Volume - position.VolumeClosed
+		price := position.Price
+		if position.Volume > 0 {
+			price = position.Cost / position.Volume
+		}
+		totals := longs
+		if position.Side == Sell {
+			totals = shorts
+			summary.NetVolume -= open
+		} else {
+			summary.NetVolume += open
+		}
+		if totals[position.Pair] == nil {
+			totals[position.Pair] = &sideTotals{}
+		}
+		totals[position.Pair].volume += open
+		totals[position.Pair].cost += open * price
+		summaries[position.Pair] = summary
+	}
+
+	for pair, summary := range summaries {
+		var side *sideTotals
+		switch {
+		case summary.NetVolume > 0:
+			side = longs[pair]
+		case summary.NetVolume < 0:
+			side = shorts[pair]
+		}
+		if side != nil && side.volume > 0 {
+			summary.AveragePrice = side.cost / side.volume
+			summaries[pair] = summary
+		}
+	}
+	return summaries
+}
+
 // LedgerInfoResponse - response on ledger request
 type LedgerInfoResponse struct {
 	Ledgers map[string]Ledger `json:"ledger"`
 }
 
+// ByAssetClass - returns ledger entries of asset class `aclass` (one of AssetClass* constants)
+func (r LedgerInfoResponse) ByAssetClass(aclass string) map[string]Ledger {
+	result := make(map[string]Ledger)
+	for id, ledger := range r.Ledgers {
+		if ledger.AssetClass == aclass {
+			result[id] = ledger
+		}
+	}
+	return result
+}
+
+// ByType - returns ledgers of type `t`
+func (r LedgerInfoResponse) ByType(t string) map[string]Ledger {
+	result := make(map[string]Ledger)
+	for id, ledger := range r.Ledgers {
+		if ledger.LedgerType == t {
+			result[id] = ledger
+		}
+	}
+	return result
+}
+
+// NetByAsset - returns sum of ledger amounts per asset. Fees are not included.
+func (r LedgerInfoResponse) NetByAsset() map[string]*decimal.Big {
+	result := make(map[string]*decimal.Big)
+	for _, ledger := range r.Ledgers {
+		net, ok := result[ledger.Asset]
+		if !ok {
+			net = newDecimal()
+			result[ledger.Asset] = net
+		}
+		net.Add(net, decimalFromFloat64(ledger.Amount))
+	}
+	return result
+}
+
 // Ledger - structure of account's ledger
 type Ledger struct {
 	RefID      string  `json:"refid"`
@@ -705,6 +1432,143 @@ type OrderDescription struct {
 	Leverage       string  `json:"leverage"`
 	Info           string  `json:"order"`
 	CloseCondition string  `json:"close"`
+	// RelativePrice - price in relative form (e.g. `+5%` for trailing stops), see ParseRelativePrice. Price is zero if it's set.
+	RelativePrice string `json:"relative_price,omitempty"`
+	// RelativePrice2 - secondary price in relative form. Price2 is zero if it's set.
+	RelativePrice2 string `json:"relative_price2,omitempty"`
+}
+
+// parseDescriptionPrice - returns either absolute price or price in relative form, see ParseRelativePrice
+func parseDescriptionPrice(str string) (float64, string, error) {
+	if str == "" {
+		return 0, "", nil
+	}
+	kind, _, err := ParseRelativePrice(str)
+	if err != nil {
+		return 0, "", err
+	}
+	if kind != PriceAbsolute {
+		return 0, str, nil
+	}
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	return f, "", nil
+}
+
+// ParseRelativePrice - parses price in one of Kraken's forms and returns its kind (one of Price* constants) and value.
+// Absolute `27500.1`, relative `+5`/`-5`, percentage `+5%`/`-5%` and pegged to bid/ask `#5` forms are supported.
+// Value of relative and percentage forms is signed.
+func ParseRelativePrice(s string) (kind string, value *decimal.Big, err error) {
+	if s == "" {
+		return "", nil, errors.New("empty price")
+	}
+
+	str := s
+	switch {
+	case strings.HasPrefix(str, "#"):
+		kind = PricePegged
+		str = str[1:]
+	case strings.HasSuffix(str, "%"):
+		kind = PricePercent
+		str = strings.TrimSuffix(str, "%")
+	case strings.ContainsAny(str[:1], "+-"):
+		kind = PriceRelative
+	default:
+		kind = PriceAbsolute
+	}
+	if str == "" {
+		return "", nil, fmt.Errorf("invalid price %q: no value", s)
+	}
+	if kind == PricePercent && !strings.ContainsAny(str[:1], "+-") {
+		return "", nil, fmt.Errorf("percentage price must be signed: %q", s)
+	}
+
+	value, err = parseDecimal(str)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid price %q: %w", s, err)
+	}
+	return kind, value, nil
+}
+
+// UnmarshalJSON - decodes order description with both absolute and relative prices
+func (d *OrderDescription) UnmarshalJSON(buf []byte) error {
+	type alias OrderDescription
+	tmp := struct {
+		*alias
+		Price  string `json:"price"`
+		Price2 string `json:"price2"`
+	}{
+		alias: (*alias)(d),
+	}
+	if err := json.Unmarshal(buf, &tmp); err != nil {
+		return err
+	}
+
+	price, relative, err := parseDescriptionPrice(tmp.Price)
+	if err != nil {
+		return err
+	}
+	d.Price = price
+	if relative != "" {
+		d.RelativePrice = relative
+	}
+	price2, relative2, err := parseDescriptionPrice(tmp.Price2)
+	if err != nil {
+		return err
+	}
+	d.Price2 = price2
+	if relative2 != "" {
+		d.RelativePrice2 = relative2
+	}
+	return nil
+}
+
+// LeverageRatio - parses leverage of `N:1` form and returns N. Returns false if order has no leverage or value is malformed.
+func (d OrderDescription) LeverageRatio() (int, bool) {
+	parts := strings.Split(d.Leverage, ":")
+	if len(parts) != 2 || parts[1] != "1" {
+		return 0, false
+	}
+	ratio, err := strconv.Atoi(parts[0])
+	if err != nil || ratio <= 0 {
+		return 0, false
+	}
+	return ratio, true
+}
+
+// ParseInfo - parses canonical order info string (e.g. `buy 1.25 XBTUSD @ limit 30000.0`) and returns side, volume and primary price.
+// Price is nil for market orders and orders with relative price. Leverage suffix (`with 5:1 leverage`) and secondary price are ignored.
+func (d OrderDescription) ParseInfo() (side string, volume, price *decimal.Big, err error) {
+	info := d.Info
+	if i := strings.Index(info, " with "); i >= 0 {
+		info = info[:i]
+	}
+	fields := strings.Fields(info)
+	if len(fields) < 5 || fields[3] != "@" {
+		return "", nil, nil, fmt.Errorf("invalid order info %q", d.Info)
+	}
+
+	side = fields[0]
+	if side != Buy && side != Sell {
+		return "", nil, nil, fmt.Errorf("invalid side in order info %q", d.Info)
+	}
+	volume, err = parseDecimal(fields[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid volume in order info %q: %w", d.Info, err)
+	}
+	for _, field := range fields[4:] {
+		kind, value, err := ParseRelativePrice(field)
+		if err != nil {
+			continue
+		}
+		if kind == PriceAbsolute {
+			price = value
+		}
+		break
+	}
+	return side, volume, price, nil
 }
 
 // AddOrderResponse - response on AddOrder request
@@ -1,8 +1,10 @@
 package rest
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ericlagergren/decimal"
 	"github.com/stretchr/testify/assert"
@@ -112,6 +114,127 @@ func Test_getFloat64FromStr(t *testing.T) {
 	}
 }
 
+func Test_getFloat64FromStr_errorContext(t *testing.T) {
+	_, err := getFloat64FromStr("12.3.4")
+	if err == nil {
+		t.Error("getFloat64FromStr() expected error")
+		return
+	}
+	assert.Contains(t, err.Error(), `"12.3.4"`)
+
+	item := &OrderBookItem{}
+	err = item.UnmarshalJSON([]byte(`["123.0", "1,24", 125]`))
+	if err == nil {
+		t.Error("OrderBookItem.UnmarshalJSON() expected error")
+		return
+	}
+	assert.Contains(t, err.Error(), "volume")
+	assert.Contains(t, err.Error(), `"1,24"`)
+}
+
+func TestTimeResponse_ParsedRFC1123(t *testing.T) {
+	tests := []struct {
+		name     string
+		response TimeResponse
+		wantErr  bool
+	}{
+		{
+			name:     "double space after weekday",
+			response: TimeResponse{Unixtime: 1554218108, Rfc1123: "Tue,  2 Apr 19 15:15:08 +0000"},
+		}, {
+			name:     "two digit day",
+			response: TimeResponse{Unixtime: 1616336594, Rfc1123: "Sun, 21 Mar 21 14:23:14 +0000"},
+		}, {
+			name:     "invalid",
+			response: TimeResponse{Rfc1123: "2019-04-02T15:15:08Z"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.response.ParsedRFC1123()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TimeResponse.ParsedRFC1123() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, time.Unix(tt.response.Unixtime, 0).UTC(), got)
+			assert.Equal(t, time.UTC, got.Location())
+		})
+	}
+}
+
+func TestKrakenResponse_Marshal(t *testing.T) {
+	response := KrakenResponse{
+		Result: TimeResponse{
+			Unixtime: 1616336594,
+			Rfc1123:  "Sun, 21 Mar 21 14:23:14 +0000",
+		},
+	}
+	data, err := response.Marshal()
+	if err != nil {
+		t.Errorf("KrakenResponse.Marshal() error = %v", err)
+		return
+	}
+	assert.Equal(t, `{"error":[],"result":{"unixtime":1616336594,"rfc1123":"Sun, 21 Mar 21 14:23:14 +0000"}}`, string(data))
+
+	var result TimeResponse
+	decoded := KrakenResponse{Result: &result}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Errorf("json.Unmarshal() error = %v", err)
+		return
+	}
+	assert.Equal(t, []string{}, decoded.Error)
+	assert.Equal(t, response.Result, result)
+}
+
+func Test_getDecimalFromStr(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "invalid type",
+			args:    123,
+			wantErr: true,
+		}, {
+			name:    "good type - invalid text",
+			args:    "text",
+			wantErr: true,
+		}, {
+			name: "plain",
+			args: "123.3",
+			want: "123.3",
+		}, {
+			name: "scientific notation",
+			args: "1e-08",
+			want: "0.00000001",
+		}, {
+			name: "scientific notation upper case",
+			args: "1.5E-7",
+			want: "0.00000015",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getDecimalFromStr(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getDecimalFromStr() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			want, _ := new(decimal.Big).SetString(tt.want)
+			assert.Equal(t, 0, got.Cmp(want), "got %s, want %s", got, tt.want)
+		})
+	}
+}
+
 func TestLevel_UnmarshalJSON(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -294,7 +417,15 @@ func TestOHLCResponse_UnmarshalJSON(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{
+			name:    "invalid json",
+			args:    args{buf: []byte(`[]`)},
+			wantErr: true,
+		}, {
+			name:    "good",
+			args:    args{buf: []byte(`{"XXBTZUSD":[[1688671200,"30306.1","30306.2","30305.7","30305.7","30306.1","3.39243896",23]],"last":1688672160}`)},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -309,6 +440,158 @@ func TestOHLCResponse_UnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestTicker_UnmarshalJSON(t *testing.T) {
+	buf := []byte(`{"a":["0.108312","6418","6418.000"],"b":["0.090125","2688","2688.000"],"c":["0.090043","0.00000091"],"v":["115805.23341809","136512.79974015"],"p":["0.102010","0.100786"],"t":[54,67],"l":["0.090000","0.090000"],"h":["0.109000","0.109000"],"o":"0.093911"}`)
+	item := new(Ticker)
+	if err := item.UnmarshalJSON(buf); err != nil {
+		t.Errorf("Ticker.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Equal(t, "0.108312", item.Ask.Price.String())
+	assert.Equal(t, "0.090125", item.Bid.Price.String())
+	assert.Equal(t, "0.090043", item.Close.Price.String())
+	assert.Equal(t, "136512.79974015", item.Volume.LotVolume.String())
+	assert.Equal(t, "0.102010", item.VolumeAveragePrice.Price.String())
+	assert.Equal(t, int64(67), item.Trades.Last24Hours)
+	assert.Equal(t, "0.090000", item.Low.Price.String())
+	assert.Equal(t, "0.109000", item.High.Price.String())
+	if assert.NotNil(t, item.OpeningPrice) {
+		assert.Equal(t, "0.093911", item.OpeningPrice.String())
+	}
+}
+
+func TestAssetPair_Margin(t *testing.T) {
+	var pair AssetPair
+	err := json.Unmarshal([]byte(`{"altname":"XBTUSD","leverage_buy":[2,3,4,5],"leverage_sell":[2,3,4,5],"margin_call":80,"margin_stop":40}`), &pair)
+	if err != nil {
+		t.Errorf("AssetPair unmarshal error = %v", err)
+		return
+	}
+	assert.Equal(t, 0, pair.MarginCallLevel().Cmp(decimal.New(80, 0)))
+	assert.Equal(t, 0, pair.MarginStopLevel().Cmp(decimal.New(40, 0)))
+	assert.Equal(t, 0, pair.InitialMargin(5).Cmp(decimal.New(2, 1)))
+	assert.Equal(t, 0, pair.InitialMargin(4).Cmp(decimal.New(25, 2)))
+	assert.Nil(t, pair.InitialMargin(0))
+}
+
+func TestAssetPair_FeeTiers(t *testing.T) {
+	var pair AssetPair
+	err := json.Unmarshal([]byte(`{"altname":"ADACAD","fees":[[0,0.26],[50000,0.24],[100000,0.22],[250000,0.2],[500000,0.18],[1000000,0.16],[2500000,0.14],[5000000,0.12],[10000000,0.1]],"fees_maker":[[0,0.16],[50000,0.14],[100000,0.12],[250000,0.1],[500000,0.08],[1000000,0.06],[2500000,0.04],[5000000,0.02],[10000000,0]],"fee_volume_currency":"ZUSD"}`), &pair)
+	if err != nil {
+		t.Errorf("AssetPair unmarshal error = %v", err)
+		return
+	}
+	tiers := pair.FeeTiers()
+	assert.Len(t, tiers, 9)
+	assert.Equal(t, "50000", tiers[1].Volume.String())
+	assert.Equal(t, "0.24", tiers[1].Percent.String())
+	makerTiers := pair.FeeMakerTiers()
+	assert.Len(t, makerTiers, 9)
+	assert.Equal(t, "0.14", makerTiers[1].Percent.String())
+
+	assert.Equal(t, "0.24", pair.FeeAt(60000).String())
+	assert.Equal(t, "0.26", pair.FeeAt(0).String())
+	assert.Equal(t, "0.22", pair.FeeAt(100000).String())
+	assert.Equal(t, "0.1", pair.FeeAt(20000000).String())
+	assert.Nil(t, AssetPair{}.FeeAt(60000))
+}
+
+func TestTicker_ChangePct(t *testing.T) {
+	dec := func(s string) *decimal.Big {
+		d, _ := new(decimal.Big).SetString(s)
+		return d
+	}
+	tests := []struct {
+		name    string
+		opening *decimal.Big
+		last    string
+		want    *decimal.Big
+	}{
+		{
+			name:    "gainer",
+			opening: dec("100"),
+			last:    "112.5",
+			want:    dec("12.5"),
+		}, {
+			name:    "loser",
+			opening: dec("200"),
+			last:    "150",
+			want:    dec("-25"),
+		}, {
+			name:    "zero opening",
+			opening: dec("0"),
+			last:    "150",
+			want:    nil,
+		}, {
+			name:    "no opening",
+			opening: nil,
+			last:    "150",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticker := Ticker{
+				Close:        CloseLevel{Price: dec(tt.last)},
+				Low:          CloseLevel{Price: dec("90")},
+				High:         CloseLevel{Price: dec("120")},
+				OpeningPrice: tt.opening,
+			}
+			got := ticker.ChangePct()
+			if tt.want == nil {
+				assert.Nil(t, got)
+			} else {
+				assert.Equal(t, 0, got.Cmp(tt.want), "got %s, want %s", got, tt.want)
+			}
+
+			low, high := ticker.DayRange()
+			assert.Equal(t, "90", low.String())
+			assert.Equal(t, "120", high.String())
+		})
+	}
+}
+
+func TestCandle_Helpers(t *testing.T) {
+	tests := []struct {
+		name        string
+		candle      Candle
+		wantRange   *decimal.Big
+		wantBody    *decimal.Big
+		wantBullish bool
+	}{
+		{
+			name: "bullish",
+			candle: Candle{
+				Open:  decimal.New(1000, 1),
+				High:  decimal.New(1250, 1),
+				Low:   decimal.New(955, 1),
+				Close: decimal.New(1205, 1),
+			},
+			wantRange:   decimal.New(295, 1),
+			wantBody:    decimal.New(205, 1),
+			wantBullish: true,
+		}, {
+			name: "bearish",
+			candle: Candle{
+				Open:  decimal.New(1205, 1),
+				High:  decimal.New(1250, 1),
+				Low:   decimal.New(955, 1),
+				Close: decimal.New(1000, 1),
+			},
+			wantRange:   decimal.New(295, 1),
+			wantBody:    decimal.New(205, 1),
+			wantBullish: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantRange.String(), tt.candle.Range().String())
+			assert.Equal(t, tt.wantBody.String(), tt.candle.Body().String())
+			assert.Equal(t, tt.wantBullish, tt.candle.IsBullish())
+		})
+	}
+}
+
 func TestOrderBookItem_UnmarshalJSON(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -336,24 +619,42 @@ func TestOrderBookItem_UnmarshalJSON(t *testing.T) {
 			buf:     []byte(`["123.0", 123, 123]`),
 			wantErr: true,
 			result: &OrderBookItem{
-				Price: 123,
+				Price:    123,
+				RawPrice: "123.0",
 			},
 		}, {
 			name:    "invalid timestamp",
 			buf:     []byte(`["123.0", "124.0", "123"]`),
 			wantErr: true,
 			result: &OrderBookItem{
-				Price:  123,
-				Volume: 124,
+				Price:     123,
+				Volume:    124,
+				RawPrice:  "123.0",
+				RawVolume: "124.0",
 			},
 		}, {
 			name:    "good",
 			buf:     []byte(`["123.0", "124.0", 125.0]`),
 			wantErr: false,
 			result: &OrderBookItem{
-				Price:     123,
-				Volume:    124,
-				Timestamp: 125,
+				Price:        123,
+				Volume:       124,
+				Timestamp:    125,
+				RawPrice:     "123.0",
+				RawVolume:    "124.0",
+				RawTimestamp: "125.0",
+			},
+		}, {
+			name:    "fractional timestamp",
+			buf:     []byte(`["123.0", "124.0", 1688671969.993150]`),
+			wantErr: false,
+			result: &OrderBookItem{
+				Price:        123,
+				Volume:       124,
+				Timestamp:    1688671969,
+				RawPrice:     "123.0",
+				RawVolume:    "124.0",
+				RawTimestamp: "1688671969.993150",
 			},
 		},
 	}
@@ -370,6 +671,20 @@ func TestOrderBookItem_UnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestOrderBook_Checksum(t *testing.T) {
+	// example book and checksum published at https://docs.kraken.com/websockets/#book-checksum with extra levels which are not included
+	buf := []byte(`{
+		"asks":[["0.05005","0.00000500",1582905487],["0.05010","0.00000500",1582905486],["0.05015","0.00000500",1582905484],["0.05020","0.00000500",1582905486],["0.05025","0.00000500",1582905486],["0.05030","0.00000500",1582905488],["0.05035","0.00000500",1582905488],["0.05040","0.00000500",1582905488],["0.05045","0.00000500",1582905488],["0.05050","0.00000500",1582905488],["0.05055","0.00000500",1582905488]],
+		"bids":[["0.05000","0.00000500",1582905487],["0.04995","0.00000500",1582905485],["0.04990","0.00000500",1582905486],["0.04980","0.00000500",1582905486],["0.04975","0.00000500",1582905486],["0.04970","0.00000500",1582905486],["0.04965","0.00000500",1582905486],["0.04960","0.00000500",1582905486],["0.04955","0.00000500",1582905486],["0.04950","0.00000500",1582905486],["0.04945","0.00000500",1582905486]]
+	}`)
+	var book OrderBook
+	if err := json.Unmarshal(buf, &book); err != nil {
+		t.Errorf("OrderBook unmarshal error = %v", err)
+		return
+	}
+	assert.Equal(t, uint32(974947235), book.Checksum())
+}
+
 func TestTrade_UnmarshalJSON(t *testing.T) {
 	type fields struct {
 		Price     float64
@@ -408,32 +723,664 @@ func TestTrade_UnmarshalJSON(t *testing.T) {
 }
 
 func TestSpread_UnmarshalJSON(t *testing.T) {
-	type fields struct {
-		Time float64
-		Bid  float64
-		Ask  float64
-	}
-	type args struct {
-		buf []byte
-	}
 	tests := []struct {
 		name    string
-		fields  fields
-		args    args
+		buf     string
+		want    Spread
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "spread",
+			buf:  `[1542057299,"5698.40000","5700.00000"]`,
+			want: Spread{Time: 1542057299, Bid: 5698.4, Ask: 5700},
+		}, {
+			name:    "wrong length",
+			buf:     `[1542057299,"5698.40000"]`,
+			wantErr: true,
+		}, {
+			name:    "websocket frame",
+			buf:     `["5698.40000","5700.00000","1542057299.545897","1.01234567","0.98765432"]`,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			item := &Spread{
-				Time: tt.fields.Time,
-				Bid:  tt.fields.Bid,
-				Ask:  tt.fields.Ask,
-			}
-			if err := item.UnmarshalJSON(tt.args.buf); (err != nil) != tt.wantErr {
+			var item Spread
+			err := json.Unmarshal([]byte(tt.buf), &item)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("Spread.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, item)
+			}
+		})
+	}
+}
+
+func TestOrderDescription_UnmarshalJSON(t *testing.T) {
+	var d OrderDescription
+	err := json.Unmarshal([]byte(`{"pair":"XBTUSD","type":"sell","ordertype":"trailing-stop-limit","price":"+5.0000%","price2":"-100","leverage":"none","order":"sell 1.25000000 XBTUSD @ trailing stop +5.0000%","close":""}`), &d)
+	if err != nil {
+		t.Errorf("OrderDescription.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Equal(t, "+5.0000%", d.RelativePrice)
+	assert.Equal(t, "-100", d.RelativePrice2)
+	assert.Equal(t, 0.0, d.Price)
+
+	buf, err := json.Marshal(d)
+	if err != nil {
+		t.Errorf("json.Marshal() error = %v", err)
+		return
+	}
+	var decoded OrderDescription
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Errorf("OrderDescription.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Equal(t, d, decoded)
+
+	err = json.Unmarshal([]byte(`{"price":"+abc%"}`), &d)
+	assert.NotNil(t, err)
+}
+
+func TestOrderDescription_LeverageRatio(t *testing.T) {
+	tests := []struct {
+		name      string
+		leverage  string
+		want      int
+		wantFound bool
+	}{
+		{
+			name:      "5:1",
+			leverage:  "5:1",
+			want:      5,
+			wantFound: true,
+		}, {
+			name:      "none",
+			leverage:  "none",
+			want:      0,
+			wantFound: false,
+		}, {
+			name:      "malformed",
+			leverage:  "x:1",
+			want:      0,
+			wantFound: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := OrderDescription{Leverage: tt.leverage}.LeverageRatio()
+			assert.Equal(t, tt.wantFound, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOrderBookItem_Time(t *testing.T) {
+	tests := []struct {
+		name string
+		item OrderBookItem
+		want time.Time
+	}{
+		{
+			name: "integer",
+			item: OrderBookItem{Timestamp: 1688671969, RawTimestamp: "1688671969"},
+			want: time.Unix(1688671969, 0),
+		}, {
+			name: "fractional",
+			item: OrderBookItem{Timestamp: 1688671969, RawTimestamp: "1688671969.993150"},
+			want: time.Unix(1688671969, 993150000),
+		}, {
+			name: "without raw timestamp",
+			item: OrderBookItem{Timestamp: 1688671969},
+			want: time.Unix(1688671969, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(t, tt.want.Equal(tt.item.Time()))
+		})
+	}
+}
+
+func TestOrderDescription_ParseInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		info       string
+		wantSide   string
+		wantVolume string
+		wantPrice  string
+		wantErr    bool
+	}{
+		{
+			name:       "limit",
+			info:       "buy 1.25000000 XBTUSD @ limit 30000.0",
+			wantSide:   Buy,
+			wantVolume: "1.25000000",
+			wantPrice:  "30000.0",
+		}, {
+			name:       "market",
+			info:       "sell 0.50000000 XBTUSD @ market",
+			wantSide:   Sell,
+			wantVolume: "0.50000000",
+		}, {
+			name:       "stop loss limit with leverage",
+			info:       "sell 2.00000000 XBTUSD @ stop loss 28000.0 -> limit 27900.0 with 5:1 leverage",
+			wantSide:   Sell,
+			wantVolume: "2.00000000",
+			wantPrice:  "28000.0",
+		}, {
+			name:       "trailing stop",
+			info:       "sell 1.00000000 XBTUSD @ trailing stop +50.0",
+			wantSide:   Sell,
+			wantVolume: "1.00000000",
+		}, {
+			name:       "trailing stop percentage with limit",
+			info:       "sell 1.00000000 XBTUSD @ trailing stop +5.0000% -> limit -10.0",
+			wantSide:   Sell,
+			wantVolume: "1.00000000",
+		}, {
+			name:       "pegged limit",
+			info:       "buy 1.00000000 XBTUSD @ limit #5.0",
+			wantSide:   Buy,
+			wantVolume: "1.00000000",
+		}, {
+			name:    "malformed",
+			info:    "buy XBTUSD",
+			wantErr: true,
+		}, {
+			name:    "invalid side",
+			info:    "hold 1.0 XBTUSD @ market",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			side, volume, price, err := OrderDescription{Info: tt.info}.ParseInfo()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("OrderDescription.ParseInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, tt.wantSide, side)
+			assert.Equal(t, tt.wantVolume, volume.String())
+			if tt.wantPrice == "" {
+				assert.Nil(t, price)
+			} else if assert.NotNil(t, price) {
+				assert.Equal(t, tt.wantPrice, price.String())
+			}
+		})
+	}
+}
+
+func TestParseRelativePrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    string
+		wantKind string
+		want     *decimal.Big
+		wantErr  bool
+	}{
+		{
+			name:     "absolute",
+			price:    "27500.1",
+			wantKind: PriceAbsolute,
+			want:     decimal.New(275001, 1),
+		}, {
+			name:     "relative",
+			price:    "-100",
+			wantKind: PriceRelative,
+			want:     decimal.New(-100, 0),
+		}, {
+			name:     "percentage",
+			price:    "+5%",
+			wantKind: PricePercent,
+			want:     decimal.New(5, 0),
+		}, {
+			name:     "pegged",
+			price:    "#5",
+			wantKind: PricePegged,
+			want:     decimal.New(5, 0),
+		}, {
+			name:    "invalid",
+			price:   "+abc%",
+			wantErr: true,
+		}, {
+			name:    "bare percent",
+			price:   "%",
+			wantErr: true,
+		}, {
+			name:    "bare pegged",
+			price:   "#",
+			wantErr: true,
+		}, {
+			name:    "bare plus",
+			price:   "+",
+			wantErr: true,
+		}, {
+			name:    "bare minus",
+			price:   "-",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, value, err := ParseRelativePrice(tt.price)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRelativePrice() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
 			}
+			assert.Equal(t, tt.wantKind, kind)
+			assert.Equal(t, 0, value.Cmp(tt.want))
 		})
 	}
 }
+
+func TestAggregateTradesToCandles(t *testing.T) {
+	trades := []Trade{
+		{Price: 100, Volume: 1, Time: 1554179640.1},
+		{Price: 105.5, Volume: 2, Time: 1554179650.2},
+		{Price: 99, Volume: 1, Time: 1554179700.3},
+		{Price: 101, Volume: 0.5, Time: 1554179770},
+	}
+	got := AggregateTradesToCandles(trades, 2*time.Minute)
+	if !assert.Len(t, got, 2) {
+		return
+	}
+
+	assert.Equal(t, int64(1554179640), got[0].Time)
+	assert.Equal(t, "100", got[0].Open.String())
+	assert.Equal(t, "105.5", got[0].High.String())
+	assert.Equal(t, "99", got[0].Low.String())
+	assert.Equal(t, "99", got[0].Close.String())
+	assert.Equal(t, "4", got[0].Volume.String())
+	assert.Equal(t, "102.5", got[0].VolumeWAP.String())
+	assert.Equal(t, int64(3), got[0].Count)
+
+	assert.Equal(t, int64(1554179760), got[1].Time)
+	assert.Equal(t, "101", got[1].Open.String())
+	assert.Equal(t, "101", got[1].High.String())
+	assert.Equal(t, "101", got[1].Low.String())
+	assert.Equal(t, "101", got[1].Close.String())
+	assert.Equal(t, "0.5", got[1].Volume.String())
+	assert.Equal(t, int64(1), got[1].Count)
+
+	assert.Len(t, AggregateTradesToCandles(nil, time.Minute), 0)
+}
+
+func TestLedgerInfoResponse_Aggregation(t *testing.T) {
+	response := LedgerInfoResponse{
+		Ledgers: map[string]Ledger{
+			"L1": {LedgerType: LedgerTypeDeposit, Asset: "ZUSD", Amount: 1000},
+			"L2": {LedgerType: LedgerTypeTrade, Asset: "ZUSD", Amount: -250.5, Fee: 0.4},
+			"L3": {LedgerType: LedgerTypeTrade, Asset: "XXBT", Amount: 0.01},
+			"L4": {LedgerType: LedgerTypeDeposit, Asset: "XXBT", Amount: 0.1},
+		},
+	}
+
+	deposits := response.ByType(LedgerTypeDeposit)
+	assert.Len(t, deposits, 2)
+	assert.Equal(t, "ZUSD", deposits["L1"].Asset)
+	assert.Equal(t, "XXBT", deposits["L4"].Asset)
+	assert.Len(t, response.ByType(LedgerTypeMargin), 0)
+
+	net := response.NetByAsset()
+	assert.Len(t, net, 2)
+	assert.Equal(t, "749.5", net["ZUSD"].String())
+	assert.Equal(t, "0.11", net["XXBT"].String())
+
+	defer SetDecimalContext(DecimalContext())
+	SetDecimalContext(decimal.Context{Precision: 40})
+	assert.Equal(t, 40, response.NetByAsset()["ZUSD"].Context.Precision)
+}
+
+func TestTradeBalanceResponse_Margin(t *testing.T) {
+	tests := []struct {
+		name           string
+		balance        TradeBalanceResponse
+		wantMarginCall bool
+		wantUsedPct    float64
+	}{
+		{
+			name: "near margin call",
+			balance: TradeBalanceResponse{
+				Equity:      850,
+				OpenMargin:  1000,
+				MarginLevel: 85,
+			},
+			wantMarginCall: true,
+			wantUsedPct:    117.647,
+		}, {
+			name: "healthy",
+			balance: TradeBalanceResponse{
+				Equity:      5000,
+				OpenMargin:  1000,
+				MarginLevel: 500,
+			},
+			wantMarginCall: false,
+			wantUsedPct:    20,
+		}, {
+			name:           "no positions",
+			balance:        TradeBalanceResponse{},
+			wantMarginCall: false,
+			wantUsedPct:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantMarginCall, tt.balance.IsMarginCall(100))
+			assert.InDelta(t, tt.wantUsedPct, tt.balance.UsedMarginPct(), 0.001)
+		})
+	}
+}
+
+func TestOHLCResponse_UnmarshalJSON_scientificNotation(t *testing.T) {
+	var item OHLCResponse
+	err := json.Unmarshal([]byte(`{"XXBTZUSD":[[1688671200,"30306.1","30306.2","30305.7","30305.7","30306.1","1e-8",1]],"last":1688672160}`), &item)
+	if err != nil {
+		t.Errorf("OHLCResponse.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Len(t, item.Candles["XXBTZUSD"], 1)
+	want, _ := new(decimal.Big).SetString("0.00000001")
+	got := item.Candles["XXBTZUSD"][0].Volume
+	assert.Equal(t, 0, got.Cmp(want), "got %s, want %s", got, want)
+}
+
+func TestOHLCResponse_UnmarshalJSON_largeIntegers(t *testing.T) {
+	var item OHLCResponse
+	err := json.Unmarshal([]byte(`{"XXBTZUSD":[[1688671200,"30306.1","30306.2","30305.7","30305.7","30306.1","3.1",9007199254740993]],"last":1688672160000000001}`), &item)
+	if err != nil {
+		t.Errorf("OHLCResponse.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Equal(t, int64(1688672160000000001), item.Last)
+	assert.Equal(t, int64(9007199254740993), item.Candles["XXBTZUSD"][0].Count)
+}
+
+func TestTradeResponse_UnmarshalJSON_numericLast(t *testing.T) {
+	var item TradeResponse
+	err := json.Unmarshal([]byte(`{"XXBTZUSD":[["30306.1","0.5",1688671200.1234,"b","l","",61000001]],"last":1688671200123400001}`), &item)
+	if err != nil {
+		t.Errorf("TradeResponse.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Equal(t, Cursor(1688671200123400001), item.Last)
+	assert.Len(t, item.Trades, 1)
+}
+
+func TestOHLCResponse_UnmarshalJSON_nullPair(t *testing.T) {
+	var item OHLCResponse
+	assert.NotPanics(t, func() {
+		err := json.Unmarshal([]byte(`{"NEWUSD":null,"last":1688672160}`), &item)
+		assert.Nil(t, err)
+	})
+	candles, ok := item.Candles["NEWUSD"]
+	assert.True(t, ok)
+	assert.Len(t, candles, 0)
+	assert.Equal(t, int64(1688672160), item.Last)
+}
+
+func TestTradeResponse_UnmarshalJSON_nullPair(t *testing.T) {
+	var item TradeResponse
+	assert.NotPanics(t, func() {
+		err := json.Unmarshal([]byte(`{"NEWUSD":null,"last":"1688671200123400001"}`), &item)
+		assert.Nil(t, err)
+	})
+	assert.Equal(t, "NEWUSD", item.Key)
+	assert.NotNil(t, item.Trades)
+	assert.Len(t, item.Trades, 0)
+}
+
+func TestOrderInfoDecimal(t *testing.T) {
+	data := []byte(`{"refid":null,"userref":0,"status":"closed","opentm":1616666559.8974,"closetm":1616666559.9037,"starttm":0,"expiretm":0,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"market","price":"0","price2":"0","leverage":"none","order":"buy 4512.123456789 XBTUSD @ market","close":""},"vol":"4512.123456789","vol_exec":"4512.123456789","cost":"123456789.123456789","fee":"197530.862597530","price":"27360.987654321","stopprice":"0.00000","limitprice":"0.00000","misc":"","oflags":"fciq"}`)
+
+	var order OrderInfo
+	if err := json.Unmarshal(data, &order); err != nil {
+		t.Errorf("OrderInfo.UnmarshalJSON() error = %v", err)
+		return
+	}
+	var exact OrderInfoDecimal
+	if err := json.Unmarshal(data, &exact); err != nil {
+		t.Errorf("OrderInfoDecimal.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Equal(t, 123456789.123456789, order.Cost)
+	assert.Equal(t, "123456789.123456789", exact.Cost.String())
+	assert.Equal(t, "4512.123456789", exact.Volume.String())
+	assert.Equal(t, "4512.123456789", exact.VolumeExecuted.String())
+	assert.Equal(t, "197530.862597530", exact.Fee.String())
+	assert.Equal(t, "27360.987654321", exact.AveragePrice.String())
+}
+
+func TestOrderInfo_IsPostOnlyReject(t *testing.T) {
+	tests := []struct {
+		name  string
+		order OrderInfo
+		want  bool
+	}{
+		{name: "Post only reject", order: OrderInfo{Status: "canceled", Reason: "Post only order"}, want: true},
+		{name: "Canceled by user", order: OrderInfo{Status: "canceled", Reason: "User requested"}, want: false},
+		{name: "Open post only order", order: OrderInfo{Status: "open", Flags: string(OFlagPostOnly)}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.order.IsPostOnlyReject())
+		})
+	}
+}
+
+func TestOrderInfo_EffectivePrice(t *testing.T) {
+	tests := []struct {
+		name  string
+		order OrderInfo
+		want  string
+	}{
+		{
+			name: "filled limit",
+			order: OrderInfo{
+				Status:         "closed",
+				Description:    OrderDescription{OrderType: OTLimit, Price: 27500},
+				Volume:         1.25,
+				VolumeExecuted: 1.25,
+				AveragePrice:   27490.5,
+			},
+			want: "27490.5",
+		}, {
+			name: "open stop loss",
+			order: OrderInfo{
+				Status:      "open",
+				Description: OrderDescription{OrderType: OTStopLoss, Price: 26000},
+				Volume:      1.25,
+			},
+			want: "26000",
+		}, {
+			name: "open stop loss limit",
+			order: OrderInfo{
+				Status:      "open",
+				Description: OrderDescription{OrderType: OTStopLossLimit, Price: 26000, Price2: 25900},
+				Volume:      1.25,
+			},
+			want: "25900",
+		}, {
+			name: "triggered stop loss limit",
+			order: OrderInfo{
+				Status:      "open",
+				Description: OrderDescription{OrderType: OTStopLossLimit, Price: 26000, Price2: 25900},
+				Volume:      1.25,
+				StopPrice:   25995,
+				LimitPrice:  25900.5,
+			},
+			want: "25900.5",
+		}, {
+			name: "open market",
+			order: OrderInfo{
+				Status:      "open",
+				Description: OrderDescription{OrderType: OTMarket},
+				Volume:      1.25,
+			},
+		}, {
+			name: "trailing stop",
+			order: OrderInfo{
+				Status:      "open",
+				Description: OrderDescription{OrderType: OTTrailingStop, RelativePrice: "+5%"},
+				Volume:      1.25,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.order.EffectivePrice()
+			if tt.want == "" {
+				assert.Nil(t, got)
+			} else if assert.NotNil(t, got) {
+				assert.Equal(t, tt.want, got.String())
+			}
+		})
+	}
+}
+
+func TestAggregatePositions(t *testing.T) {
+	positions := map[string]Position{
+		"TYE7IH-QCG76-BVMCM1": {
+			Pair:   "XXBTZUSD",
+			Side:   Buy,
+			Cost:   60000,
+			Volume: 2,
+			Margin: 12000,
+			Profit: 150,
+		},
+		"TJUW2K-FLX2N-AR2FLU": {
+			Pair:         "XXBTZUSD",
+			Side:         Sell,
+			Cost:         31000,
+			Volume:       1,
+			VolumeClosed: 0.5,
+			Margin:       3100,
+			Profit:       -20,
+		},
+		"TZX2WP-XSEOP-FP7WYR": {
+			Pair:   "XETHZUSD",
+			Side:   Sell,
+			Cost:   3800,
+			Volume: 2,
+			Margin: 760,
+		},
+	}
+	got := AggregatePositions(positions)
+	if !assert.Len(t, got, 2) {
+		return
+	}
+
+	btc := got["XXBTZUSD"]
+	assert.InDelta(t, 1.5, btc.NetVolume, 1e-9)
+	assert.InDelta(t, 30000, btc.AveragePrice, 1e-9)
+	assert.InDelta(t, 15100, btc.Margin, 1e-9)
+	assert.InDelta(t, 130, btc.Profit, 1e-9)
+
+	eth := got["XETHZUSD"]
+	assert.InDelta(t, -2, eth.NetVolume, 1e-9)
+	assert.InDelta(t, 1900, eth.AveragePrice, 1e-9)
+}
+
+func TestResponses_Count(t *testing.T) {
+	var trades TradeResponse
+	if err := json.Unmarshal([]byte(`{"XXBTZUSD":[["30306.1","0.5",1688671200.1234,"b","l","",61000001],["30306.2","0.1",1688671201.5,"s","m","",61000002]],"last":"1688671201500000000"}`), &trades); err != nil {
+		t.Errorf("TradeResponse.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Equal(t, len(trades.Trades), trades.Count())
+	assert.Equal(t, 2, trades.Count())
+
+	var ohlc OHLCResponse
+	if err := json.Unmarshal([]byte(`{"XXBTZUSD":[[1688671200,"30306.1","30306.2","30305.7","30305.7","30306.1","3.1",5]],"last":1688671200}`), &ohlc); err != nil {
+		t.Errorf("OHLCResponse.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Equal(t, len(ohlc.Candles["XXBTZUSD"]), ohlc.Count("XXBTZUSD"))
+	assert.Equal(t, 1, ohlc.Count("XXBTZUSD"))
+	assert.Equal(t, 0, ohlc.Count("XETHZUSD"))
+}
+
+func TestDecimalContext(t *testing.T) {
+	defer SetDecimalContext(DecimalContext())
+	SetDecimalContext(decimal.Context{Precision: 40})
+
+	var ticker Ticker
+	err := json.Unmarshal([]byte(`{"c":["0.123456789012345678901234567890","1"],"o":"0.023456789012345678901234567890"}`), &ticker)
+	if err != nil {
+		t.Errorf("Ticker.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Equal(t, "0.123456789012345678901234567890", ticker.Close.Price.String())
+	assert.Equal(t, 40, ticker.Close.Price.Context.Precision)
+
+	candle := Candle{
+		High: ticker.Close.Price,
+		Low:  ticker.OpeningPrice,
+	}
+	assert.Equal(t, "0.100000000000000000000000000000", candle.Range().String())
+}
+
+func TestSetDecimalContext_concurrent(t *testing.T) {
+	defer SetDecimalContext(DecimalContext())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			SetDecimalContext(decimal.Context{Precision: 20 + i%2})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := parseDecimal("1.5"); err != nil {
+			t.Errorf("parseDecimal() error = %v", err)
+		}
+	}
+	<-done
+}
+
+func TestSpreadResponse_Pair(t *testing.T) {
+	var response SpreadResponse
+	err := json.Unmarshal([]byte(`{"XXBTZUSD":[[1554224145,"5183.10000","5183.20000"],[1554224146,"5183.00000","5183.20000"]],"NEWUSD":[[1554224147,"1.1","1.2"]],"last":1554224725}`), &response)
+	if err != nil {
+		t.Errorf("SpreadResponse.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Len(t, response.XXBTZUSD, 2)
+	assert.Equal(t, response.XXBTZUSD, response.Pair("XXBTZUSD"))
+	assert.Len(t, response.Pair("NEWUSD"), 1)
+	assert.Nil(t, response.Pair("XETHZUSD"))
+	assert.Equal(t, Cursor(1554224725), response.Last)
+}
+
+func TestCursor(t *testing.T) {
+	var trades TradeResponse
+	if err := json.Unmarshal([]byte(`{"XXBTZUSD":[],"last":"1688671969993150842"}`), &trades); err != nil {
+		t.Errorf("TradeResponse.UnmarshalJSON() error = %v", err)
+		return
+	}
+	tradesCursor := trades.Last
+	assert.Equal(t, Cursor(1688671969993150842), tradesCursor)
+	assert.Equal(t, "1688671969993150842", tradesCursor.String())
+	assert.Equal(t, tradesCursor, TradesCursor(time.Unix(0, 1688671969993150842)))
+
+	var spread struct {
+		Last Cursor `json:"last"`
+	}
+	if err := json.Unmarshal([]byte(`{"last":1688672106}`), &spread); err != nil {
+		t.Errorf("Cursor.UnmarshalJSON() error = %v", err)
+		return
+	}
+	assert.Equal(t, "1688672106", spread.Last.String())
+	assert.Equal(t, spread.Last, SpreadCursor(time.Unix(1688672106, 0)))
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"last":1.5}`), &spread))
+}
@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// BookStore - concurrency-safe registry of order books keyed by websocket pair name (e.g. `XBT/EUR`).
+type BookStore struct {
+	depth int
+	books map[string]*bookFeed
+
+	mx sync.RWMutex
+}
+
+// NewBookStore - creates order book registry. `depth` is a requested depth from Kraken for every tracked pair.
+func NewBookStore(depth int) *BookStore {
+	return &BookStore{
+		depth: depth,
+		books: make(map[string]*bookFeed),
+	}
+}
+
+// Track - registers empty order book of `pair`. Precisions are required for checksum verification, see NewOrderBook.
+func (s *BookStore) Track(pair string, pricePrecision, volumePrecision int) {
+	feed := &bookFeed{
+		book: NewOrderBook(s.depth, pricePrecision, volumePrecision),
+	}
+
+	s.mx.Lock()
+	s.books[pair] = feed
+	s.mx.Unlock()
+}
+
+// Get - returns order book of `pair`. If pair is not tracked returns false
+func (s *BookStore) Get(pair string) (*OrderBook, bool) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	feed, ok := s.books[pair]
+	if !ok {
+		return nil, false
+	}
+	return feed.book, true
+}
+
+// Pairs - returns tracked pairs
+func (s *BookStore) Pairs() []string {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	pairs := make([]string, 0, len(s.books))
+	for pair := range s.books {
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+func (s *BookStore) apply(upd Update) error {
+	data, ok := upd.Data.(OrderBookUpdate)
+	if !ok {
+		return nil
+	}
+	s.mx.RLock()
+	feed, ok := s.books[upd.Pair]
+	s.mx.RUnlock()
+	if !ok {
+		return nil
+	}
+	return feed.apply(data)
+}
+
+// bookFeed - order book of one pair kept up to date by websocket updates.
+// After checksum mismatch order book is cleared and deltas are dropped until fresh snapshot arrives, so deltas already queued for the consumer don't trigger another resync.
+type bookFeed struct {
+	book     *OrderBook
+	awaiting bool
+}
+
+// apply - applies update to order book. ErrChecksumMismatch is returned once per resync: caller should resync subscription then.
+func (f *bookFeed) apply(upd OrderBookUpdate) error {
+	if upd.IsSnapshot {
+		f.book.reset()
+		f.awaiting = false
+	} else if f.awaiting {
+		return nil
+	}
+	err := f.book.ApplyUpdate(upd, true)
+	var mismatch *ErrChecksumMismatch
+	if errors.As(err, &mismatch) {
+		f.book.reset()
+		f.awaiting = true
+	}
+	return err
+}
+
+// SyncBooks - subscribes to book updates of all pairs tracked by `store` and keeps their order books up to date until `ctx` is done.
+// Order book is cleared and resynced on checksum mismatch. Updates are received by own listener (see AddListener), so Listen channel is not affected.
+// Book subscription is shared with other SyncBooks and LiveOrderBook calls of the same pair and depth: it's unsubscribed when the last of them is done.
+func (k *Kraken) SyncBooks(ctx context.Context, store *BookStore) error {
+	pairs := store.Pairs()
+	if len(pairs) == 0 {
+		return errors.New("no pairs tracked by book store")
+	}
+	depth := int64(store.depth)
+
+	updates, remove := k.AddListener()
+	if fresh := k.holdBooks(pairs, depth); len(fresh) > 0 {
+		if err := k.SubscribeBook(fresh, depth); err != nil {
+			k.releaseBooks(pairs, depth)
+			remove()
+			return err
+		}
+	}
+
+	go func() {
+		defer remove()
+		for {
+			select {
+			case <-ctx.Done():
+				if unused := k.releaseBooks(pairs, depth); len(unused) > 0 {
+					if err := k.UnsubscribeBook(unused, depth); err != nil {
+						log.Error(err)
+					}
+				}
+				return
+			case upd, ok := <-updates:
+				if !ok {
+					return
+				}
+				if err := store.apply(upd); err != nil {
+					log.Error(err)
+					var mismatch *ErrChecksumMismatch
+					if errors.As(err, &mismatch) {
+						if err := k.Resync(upd.Pair); err != nil {
+							log.Error(err)
+						}
+					}
+				}
+			}
+		}
+	}()
+
+	return nil
+}
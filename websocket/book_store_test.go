@@ -0,0 +1,208 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ericlagergren/decimal"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookStore_Get(t *testing.T) {
+	store := NewBookStore(10)
+	store.Track("XBT/EUR", 1, 8)
+
+	book, ok := store.Get("XBT/EUR")
+	assert.True(t, ok)
+	assert.NotNil(t, book)
+
+	_, ok = store.Get("ETH/EUR")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"XBT/EUR"}, store.Pairs())
+}
+
+func TestKraken_SyncBooks(t *testing.T) {
+	store := NewBookStore(10)
+	store.Track("XBT/EUR", 1, 8)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKraken(ProdBaseURL)
+	if err := k.SyncBooks(ctx, store); err != nil {
+		t.Error("could not sync books:", err)
+		return
+	}
+
+	snapshot := `[336,{"as":[["50252.10000","1.50000000","1638472269.482087"]],"bs":[["50251.20000","2.00000000","1638472269.482087"]]},"book-10","XBT/EUR"]`
+	if err := k.handleMessage([]byte(snapshot)); err != nil {
+		t.Error("could not handle message:", err)
+		return
+	}
+
+	book, _ := store.Get("XBT/EUR")
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := book.Asks.Get(decimal.New(502521, 1)); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	price, volume := book.Asks.Best()
+	assert.Equal(t, 0, price.Cmp(decimal.New(502521, 1)))
+	assert.Equal(t, 0, volume.Cmp(decimal.New(15, 1)))
+
+	// Listen channel still receives the update consumed by SyncBooks
+	upd := <-k.Listen()
+	_, ok := upd.Data.(OrderBookUpdate)
+	assert.True(t, ok)
+	assert.Equal(t, "XBT/EUR", upd.Pair)
+}
+
+func TestKraken_SyncBooks_resyncOnChecksumMismatch(t *testing.T) {
+	const (
+		subscribed   = `{"channelID":336,"channelName":"book-10","event":"subscriptionStatus","pair":"XBT/EUR","status":"subscribed","subscription":{"depth":10,"name":"book"}}`
+		unsubscribed = `{"channelID":336,"channelName":"book-10","event":"subscriptionStatus","pair":"XBT/EUR","status":"unsubscribed","subscription":{"depth":10,"name":"book"}}`
+		snapshot     = `[336,{"as":[["50252.10000","1.50000000","1638472269.482087"]],"bs":[["50251.20000","2.00000000","1638472269.482087"]]},"book-10","XBT/EUR"]`
+		mismatch     = `[336,{"a":[["5025%d.20000","1.00000000","1638472270.482087"]],"c":"123"},"book-10","XBT/EUR"]`
+	)
+	delta := fmt.Sprintf(`[336,{"b":[["50251.30000","0.50000000","1638472271.482087"]],"c":"%s"},"book-10","XBT/EUR"]`, checksumOf(
+		[2]string{"50252.10000", "1.50000000"},
+		[2]string{"50251.30000", "0.50000000"},
+		[2]string{"50251.20000", "2.00000000"},
+	))
+
+	var subscribes int
+	served := make(chan struct{})
+	url := newTestServer(t, func(conn *websocket.Conn) {
+		defer close(served)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req EventType
+			if err := json.Unmarshal(data, &req); err != nil {
+				t.Error("could not parse request:", err)
+				return
+			}
+			var replies []string
+			switch req.Event {
+			case EventSubscribe:
+				subscribes++
+				replies = []string{subscribed, snapshot}
+				if subscribes == 1 {
+					// deltas sent after the mismatching one are queued before the client resyncs
+					for i := 3; i < 8; i++ {
+						replies = append(replies, fmt.Sprintf(mismatch, i))
+					}
+				} else {
+					replies = append(replies, delta)
+				}
+			case EventUnsubscribe:
+				replies = []string{unsubscribed}
+			}
+			for _, reply := range replies {
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(reply)); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	store := NewBookStore(10)
+	store.Track("XBT/EUR", 5, 8)
+
+	k := NewKraken(url)
+	if err := k.Connect(); err != nil {
+		t.Error("could not connect:", err)
+		return
+	}
+	if err := k.SyncBooks(context.Background(), store); err != nil {
+		t.Error("could not sync books:", err)
+		return
+	}
+
+	book, _ := store.Get("XBT/EUR")
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := book.Bids.Get(decimal.New(5025130, 2)); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, k.Close())
+	<-served
+
+	assert.Equal(t, 2, subscribes)
+	price, _ := book.Bids.Best()
+	assert.Equal(t, 0, price.Cmp(decimal.New(5025130, 2)))
+	price, _ = book.Asks.Best()
+	assert.Equal(t, 0, price.Cmp(decimal.New(5025210, 2)))
+}
+
+func TestKraken_Resync_notSubscribed(t *testing.T) {
+	k := NewKraken(ProdBaseURL)
+	assert.NotNil(t, k.Resync("XBT/EUR"))
+}
+
+func TestKraken_SyncBooks_noPairs(t *testing.T) {
+	k := NewKraken(ProdBaseURL)
+	err := k.SyncBooks(context.Background(), NewBookStore(10))
+	assert.NotNil(t, err)
+}
+
+func TestBookStore_concurrentAccess(t *testing.T) {
+	pairs := []string{"XBT/EUR", "ETH/EUR", "XBT/USD"}
+	store := NewBookStore(10)
+
+	var wg sync.WaitGroup
+	for _, pair := range pairs {
+		wg.Add(1)
+		go func(pair string) {
+			defer wg.Done()
+			store.Track(pair, 1, 8)
+			for i := 0; i < 100; i++ {
+				price := json.Number(strconv.Itoa(50000 + i))
+				err := store.apply(Update{
+					Pair: pair,
+					Data: OrderBookUpdate{
+						Asks:       []OrderBookItem{{Price: price, Volume: "1.5", Time: "1638472269"}},
+						Bids:       []OrderBookItem{{Price: price, Volume: "2", Time: "1638472269"}},
+						IsSnapshot: true,
+					},
+				})
+				assert.Nil(t, err)
+			}
+		}(pair)
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for _, pair := range pairs {
+					if book, ok := store.Get(pair); ok {
+						book.Asks.Best()
+						book.Bids.Best()
+					}
+				}
+				store.Pairs()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, pair := range pairs {
+		book, ok := store.Get(pair)
+		assert.True(t, ok)
+		price, _ := book.Asks.Best()
+		assert.Equal(t, 0, price.Cmp(decimal.New(50099, 0)))
+	}
+}
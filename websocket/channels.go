@@ -2,60 +2,125 @@ package websocket
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
 	"strings"
 )
 
-func (k *Kraken) handleChannel(data []byte) error {
-	var msg Message
-	if err := json.Unmarshal(data, &msg); err != nil {
-		return err
+// ErrSequenceGap - is sent as data of Update to Listen channel when frames of authenticated channel were missed.
+// Consumers should re-fetch state of the channel via REST API.
+type ErrSequenceGap struct {
+	Channel  string
+	Expected int64
+	Got      int64
+}
+
+// Error -
+func (e *ErrSequenceGap) Error() string {
+	return fmt.Sprintf("sequence gap in %s: expected %d, got %d", e.Channel, e.Expected, e.Got)
+}
+
+// checkSequence - returns error if sequence of authenticated channel message is not next after previous one.
+// Sequence 1 starts new subscription.
+func (k *Kraken) checkSequence(msg Message) *ErrSequenceGap {
+	got := msg.Sequence.Value
+	last, ok := k.sequences[msg.ChannelName]
+	k.sequences[msg.ChannelName] = got
+	if !ok || got == 1 || got == last+1 {
+		return nil
+	}
+	return &ErrSequenceGap{
+		Channel:  msg.ChannelName,
+		Expected: last + 1,
+		Got:      got,
 	}
+}
 
-	channel := strings.Split(msg.ChannelName, "-")[0]
-	switch channel {
-	case ChanTicker:
-		var ticker TickerUpdate
-		if err := json.Unmarshal(msg.Data, &ticker); err != nil {
-			return err
-		}
-		k.msg <- msg.toUpdate(ticker)
-	case ChanCandles:
-		var candle Candle
-		if err := json.Unmarshal(msg.Data, &candle); err != nil {
-			return err
-		}
-		k.msg <- msg.toUpdate(candle)
-	case ChanTrades:
-		var trades []Trade
-		if err := json.Unmarshal(msg.Data, &trades); err != nil {
-			return err
-		}
-		k.msg <- msg.toUpdate(trades)
-	case ChanSpread:
-		var spread Spread
-		if err := json.Unmarshal(msg.Data, &spread); err != nil {
-			return err
-		}
-		k.msg <- msg.toUpdate(spread)
-	case ChanBook:
-		var update OrderBookUpdate
-		if err := json.Unmarshal(msg.Data, &update); err != nil {
+// channelHandler - decodes data of channel message and sends updates to Listen channel
+type channelHandler func(msg Message) error
+
+// defaultChannelHandlers - returns handlers of channels supported by Kraken keyed by channel name without suffix (e.g. `book` for `book-10`)
+func (k *Kraken) defaultChannelHandlers() map[string]channelHandler {
+	return map[string]channelHandler{
+		ChanTicker:     decodeChannel[TickerUpdate](k),
+		ChanCandles:    k.handleCandle,
+		ChanTrades:     decodeChannel[[]Trade](k),
+		ChanSpread:     decodeChannel[Spread](k),
+		ChanBook:       k.handleBook,
+		ChanOwnTrades:  decodeSequencedChannel[OwnTradesUpdate](k),
+		ChanOpenOrders: decodeSequencedChannel[OpenOrdersUpdate](k),
+	}
+}
+
+// registerChannel - sets `handler` of channel `name`. Messages of channels without handler are ignored.
+func (k *Kraken) registerChannel(name string, handler channelHandler) {
+	k.channels[name] = handler
+}
+
+// decodeChannel - returns handler which decodes message data into T and sends it as is
+func decodeChannel[T any](k *Kraken) channelHandler {
+	return func(msg Message) error {
+		var data T
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
 			return err
 		}
-		k.msg <- msg.toUpdate(update)
-	case ChanOwnTrades:
-		var update OwnTradesUpdate
-		if err := json.Unmarshal(msg.Data, &update); err != nil {
+		k.publish(msg.toUpdate(data))
+		return nil
+	}
+}
+
+// decodeSequencedChannel - returns handler of authenticated channel which also reports sequence gaps
+func decodeSequencedChannel[T any](k *Kraken) channelHandler {
+	return func(msg Message) error {
+		var data T
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
 			return err
 		}
-		k.msg <- msg.toUpdate(update)
-	case ChanOpenOrders:
-		var update OpenOrdersUpdate
-		if err := json.Unmarshal(msg.Data, &update); err != nil {
-			return err
+		if gap := k.checkSequence(msg); gap != nil {
+			k.publish(msg.toUpdate(gap))
 		}
-		k.msg <- msg.toUpdate(update)
+		k.publish(msg.toUpdate(data))
+		return nil
+	}
+}
+
+func (k *Kraken) handleBook(msg Message) error {
+	var update OrderBookUpdate
+	if err := json.Unmarshal(msg.Data, &update); err != nil {
+		return err
+	}
+	if k.skipBookUpdate(msg.Pair, update.IsSnapshot) {
+		return nil
 	}
+	k.publish(msg.toUpdate(update))
+	return nil
+}
 
+func (k *Kraken) handleCandle(msg Message) error {
+	var candle Candle
+	if err := json.Unmarshal(msg.Data, &candle); err != nil {
+		return err
+	}
+	if _, suffix, ok := strings.Cut(msg.ChannelName, "-"); ok {
+		interval, err := strconv.ParseInt(suffix, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid interval of channel %s: %w", msg.ChannelName, err)
+		}
+		candle.Interval = interval
+	}
+	k.publish(msg.toUpdate(candle))
 	return nil
 }
+
+func (k *Kraken) handleChannel(data []byte) error {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+
+	handler, ok := k.channels[strings.Split(msg.ChannelName, "-")[0]]
+	if !ok {
+		return nil
+	}
+	return handler(msg)
+}
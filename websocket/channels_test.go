@@ -0,0 +1,141 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKraken_handleChannel_sequenceGap(t *testing.T) {
+	k := NewKraken(ProdBaseURL)
+	frames := []string{
+		`[[],"openOrders",{"sequence":1}]`,
+		`[[],"openOrders",{"sequence":2}]`,
+		`[[],"openOrders",{"sequence":4}]`,
+		`[[],"openOrders",{"sequence":5}]`,
+	}
+	for _, frame := range frames {
+		if err := k.handleMessage([]byte(frame)); err != nil {
+			t.Error("could not handle message:", err)
+			return
+		}
+	}
+
+	gaps := make([]*ErrSequenceGap, 0)
+	for len(k.msg) > 0 {
+		upd := <-k.Listen()
+		if err, ok := upd.Data.(error); ok {
+			var gap *ErrSequenceGap
+			if assert.True(t, errors.As(err, &gap)) {
+				gaps = append(gaps, gap)
+			}
+		}
+	}
+	assert.Equal(t, []*ErrSequenceGap{{Channel: ChanOpenOrders, Expected: 3, Got: 4}}, gaps)
+}
+
+func TestKraken_handleChannel_sequenceRestart(t *testing.T) {
+	k := NewKraken(ProdBaseURL)
+	frames := []string{
+		`[[],"ownTrades",{"sequence":7}]`,
+		`[[],"ownTrades",{"sequence":1}]`,
+		`[[],"ownTrades",{"sequence":2}]`,
+	}
+	for _, frame := range frames {
+		if err := k.handleMessage([]byte(frame)); err != nil {
+			t.Error("could not handle message:", err)
+			return
+		}
+	}
+	assert.Len(t, k.msg, len(frames))
+}
+
+func TestKraken_handleChannel_dispatch(t *testing.T) {
+	frames := []struct {
+		frame   string
+		channel string
+		pair    string
+	}{
+		{frame: `[1,{"a":["5525.40000",1,1,"1.000"]},"ticker","XBT/USD"]`, channel: ChanTicker, pair: "XBT/USD"},
+		{frame: `[2,["1542057314.748456","1542057360.435743","3586.70000","3586.70000","3586.60000","3586.60000","3586.68894","0.03373000",2],"ohlc-5","XBT/USD"]`, channel: ChanCandles, pair: "XBT/USD"},
+		{frame: `[3,[["5541.20000","0.15850568","1534614057.321597","s","l",""]],"trade","XBT/USD"]`, channel: ChanTrades, pair: "XBT/USD"},
+		{frame: `[4,["5698.40000","5700.00000","1542057299.545897","1.01234567","0.98765432"],"spread","XBT/USD"]`, channel: ChanSpread, pair: "XBT/USD"},
+		{frame: `[5,{"as":[["5541.30000","2.50700000","1534614248.123678"]]},"book-10","XBT/USD"]`, channel: ChanBook, pair: "XBT/USD"},
+		{frame: `[[],"ownTrades",{"sequence":1}]`, channel: ChanOwnTrades},
+		{frame: `[[],"openOrders",{"sequence":1}]`, channel: ChanOpenOrders},
+	}
+
+	k := NewKraken(ProdBaseURL)
+	routed := make(map[string]Message)
+	for _, name := range []string{ChanTicker, ChanCandles, ChanTrades, ChanSpread, ChanBook, ChanOwnTrades, ChanOpenOrders} {
+		name := name
+		k.registerChannel(name, func(msg Message) error {
+			routed[name] = msg
+			return nil
+		})
+	}
+
+	for _, tt := range frames {
+		if err := k.handleMessage([]byte(tt.frame)); err != nil {
+			t.Error("could not handle message:", err)
+			return
+		}
+		msg, ok := routed[tt.channel]
+		if assert.True(t, ok, tt.channel) {
+			assert.Equal(t, tt.pair, msg.Pair)
+		}
+	}
+	assert.Len(t, routed, len(frames))
+	assert.Len(t, k.msg, 0)
+}
+
+func TestKraken_handleChannel_defaultHandlers(t *testing.T) {
+	k := NewKraken(ProdBaseURL)
+	frames := []string{
+		`[1,{"a":["5525.40000",1,1,"1.000"]},"ticker","XBT/USD"]`,
+		`[3,[["5541.20000","0.15850568","1534614057.321597","s","l",""]],"trade","XBT/USD"]`,
+		`[6,{"a":["5525.40000",1,1,"1.000"]},"unknown","XBT/USD"]`,
+	}
+	for _, frame := range frames {
+		if err := k.handleMessage([]byte(frame)); err != nil {
+			t.Error("could not handle message:", err)
+			return
+		}
+	}
+	if !assert.Len(t, k.msg, 2) {
+		return
+	}
+	_, ok := (<-k.Listen()).Data.(TickerUpdate)
+	assert.True(t, ok)
+	_, ok = (<-k.Listen()).Data.([]Trade)
+	assert.True(t, ok)
+}
+
+func TestKraken_handleChannel_candle(t *testing.T) {
+	k := NewKraken(ProdBaseURL)
+	frame := `[2,["1542057314.748456","1542057360.435743","3586.70000","3586.70000","3586.60000","3586.60000","3586.68894","0.03373000",2],"ohlc-5","XBT/USD"]`
+	if err := k.handleMessage([]byte(frame)); err != nil {
+		t.Error("could not handle message:", err)
+		return
+	}
+	if !assert.Len(t, k.msg, 1) {
+		return
+	}
+	candle, ok := (<-k.Listen()).Data.(Candle)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, int64(5), candle.Interval)
+	end, err := candle.End()
+	if err != nil {
+		t.Error("could not parse end time:", err)
+		return
+	}
+	assert.Equal(t, int64(1542057360), end.Unix())
+	assert.InDelta(t, 435743000, end.Nanosecond(), 1000)
+	assert.False(t, candle.IsClosed(time.Unix(1542057314, 0)))
+	assert.True(t, candle.IsClosed(time.Unix(1542057361, 0)))
+	assert.False(t, Candle{EndTime: "invalid"}.IsClosed(time.Now()))
+}
@@ -6,6 +6,8 @@ const (
 	AuthBaseURL        = "wss://ws-auth.kraken.com"
 	SandboxBaseURL     = "wss://beta-ws.kraken.com"
 	AuthSandboxBaseURL = "wss://beta-ws-auth.kraken.com"
+	ProdV2BaseURL      = "wss://ws.kraken.com/v2"
+	AuthV2BaseURL      = "wss://ws-auth.kraken.com/v2"
 )
 
 // Available channels
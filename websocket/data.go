@@ -3,7 +3,10 @@ package websocket
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"time"
 
+	"github.com/ericlagergren/decimal"
 	"github.com/pkg/errors"
 )
 
@@ -149,6 +152,8 @@ type Candle struct {
 	VolumeWAP json.Number
 	Volume    json.Number
 	Count     int64
+	// Interval - candle interval in minutes taken from channel name (e.g. 5 for `ohlc-5`). It's not a part of candle frame.
+	Interval int64
 }
 
 // UnmarshalJSON - unmarshal candle update
@@ -157,6 +162,25 @@ func (c *Candle) UnmarshalJSON(data []byte) error {
 	return json.Unmarshal(data, &raw)
 }
 
+// End - returns end time of candle interval
+func (c Candle) End() (time.Time, error) {
+	f, err := c.EndTime.Float64()
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "invalid candle end time")
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), nil
+}
+
+// IsClosed - returns true if candle interval is over at `now`, so candle won't be updated anymore. In-progress candles are updated by every trade.
+func (c Candle) IsClosed(now time.Time) bool {
+	end, err := c.End()
+	if err != nil {
+		return false
+	}
+	return !now.Before(end)
+}
+
 // Trade - data structure for trade update
 type Trade struct {
 	Price     json.Number
@@ -182,12 +206,30 @@ type Spread struct {
 	Time      json.Number
 }
 
-// UnmarshalJSON - unmarshal candle update
+// UnmarshalJSON - unmarshal spread update `[bid, ask, timestamp, bidVolume, askVolume]`
 func (s *Spread) UnmarshalJSON(data []byte) error {
-	raw := []interface{}{&s.Bid, &s.Ask, &s.Time, &s.AskVolume, &s.BidVolume, &s.Time}
+	raw := []interface{}{&s.Bid, &s.Ask, &s.Time, &s.BidVolume, &s.AskVolume}
 	return json.Unmarshal(data, &raw)
 }
 
+// BidVolumeDecimal - returns exact volume at best bid price
+func (s Spread) BidVolumeDecimal() (*decimal.Big, error) {
+	volume := new(decimal.Big)
+	if err := volume.UnmarshalText([]byte(s.BidVolume.String())); err != nil {
+		return nil, errors.Wrap(err, "invalid spread bid volume")
+	}
+	return volume, nil
+}
+
+// AskVolumeDecimal - returns exact volume at best ask price
+func (s Spread) AskVolumeDecimal() (*decimal.Big, error) {
+	volume := new(decimal.Big)
+	if err := volume.UnmarshalText([]byte(s.AskVolume.String())); err != nil {
+		return nil, errors.Wrap(err, "invalid spread ask volume")
+	}
+	return volume, nil
+}
+
 // OrderBookItem - data structure for order book item
 type OrderBookItem struct {
 	Price     json.Number
@@ -47,3 +47,35 @@ func TestOrderBookMessageAskBid(t *testing.T) {
 		t.Error("expected 2 bids, got", len(update.Bids))
 	}
 }
+
+func TestSpreadMessage(t *testing.T) {
+	var spread Spread
+	if err := json.Unmarshal([]byte(`["5698.40000","5700.00000","1542057299.545897","1.01234567","0.98765432"]`), &spread); err != nil {
+		t.Error("could not parse spread:", err)
+		return
+	}
+	if spread.Bid != "5698.40000" || spread.Ask != "5700.00000" {
+		t.Errorf("unexpected prices: bid %s ask %s", spread.Bid, spread.Ask)
+	}
+	if spread.Time != "1542057299.545897" {
+		t.Error("unexpected time", spread.Time)
+	}
+	if spread.BidVolume != "1.01234567" {
+		t.Error("expected bid volume 1.01234567, got", spread.BidVolume)
+	}
+	if spread.AskVolume != "0.98765432" {
+		t.Error("expected ask volume 0.98765432, got", spread.AskVolume)
+	}
+
+	bidVolume, err := spread.BidVolumeDecimal()
+	if err != nil || bidVolume.String() != "1.01234567" {
+		t.Errorf("unexpected decimal bid volume %v: %v", bidVolume, err)
+	}
+	askVolume, err := spread.AskVolumeDecimal()
+	if err != nil || askVolume.String() != "0.98765432" {
+		t.Errorf("unexpected decimal ask volume %v: %v", askVolume, err)
+	}
+	if _, err := (Spread{}).BidVolumeDecimal(); err == nil {
+		t.Error("expected error of empty bid volume")
+	}
+}
@@ -2,6 +2,8 @@ package websocket
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -38,7 +40,11 @@ func (k *Kraken) handleEvent(msg []byte) error {
 
 func (k *Kraken) handleEventPong(data []byte) error {
 	var pong PongResponse
-	return json.Unmarshal(data, &pong)
+	if err := json.Unmarshal(data, &pong); err != nil {
+		return err
+	}
+	k.handlePong(pong.ReqID)
+	return nil
 }
 
 func (k *Kraken) handleEventSystemStatus(data []byte) error {
@@ -60,6 +66,17 @@ func (k *Kraken) handleEventSubscriptionStatus(data []byte) error {
 
 	if status.Status == SubscriptionStatusError {
 		log.Errorf("%s: %s", status.Error, status.Pair)
+		err := &ErrSubscription{
+			Name:    status.Subscription.Name,
+			Pair:    status.Pair,
+			Message: status.Error,
+		}
+		k.resolveSubscription(status.Subscription.Name, status.Pair, err)
+		k.publish(Update{
+			ChannelName: status.Subscription.Name,
+			Pair:        status.Pair,
+			Data:        err,
+		})
 	} else {
 		log.Infof("\tStatus: %s", status.Status)
 		log.Infof("\tPair: %s", status.Pair)
@@ -67,15 +84,57 @@ func (k *Kraken) handleEventSubscriptionStatus(data []byte) error {
 		log.Infof("\tChannel ID: %d", status.ChannelID)
 		log.Infof("\tReq ID: %s", status.ReqID)
 
+		k.subMx.Lock()
 		if status.Status == SubscriptionStatusSubscribed {
 			k.subscriptions[status.ChannelID] = &status
 		} else if status.Status == SubscriptionStatusUnsubscribed {
 			delete(k.subscriptions, status.ChannelID)
 		}
+		k.subMx.Unlock()
+
+		if status.Status == SubscriptionStatusSubscribed {
+			k.resolveSubscription(status.Subscription.Name, status.Pair, nil)
+		}
 	}
 	return nil
 }
 
+// ErrSubscription - subscription error reported by Kraken (e.g. unknown pair or unsupported depth).
+// It's returned by Subscribe and sent as data of Update to Listen channel.
+type ErrSubscription struct {
+	Name    string
+	Pair    string
+	Message string
+}
+
+// Error -
+func (e *ErrSubscription) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("subscription of %s failed: %s", e.Pair, e.Message)
+	}
+	return fmt.Sprintf("subscription to %s of %s failed: %s", e.Name, e.Pair, e.Message)
+}
+
+func subscriptionKey(name, pair string) string {
+	return name + "|" + pair
+}
+
+// resolveSubscription - notifies Subscribe calls waiting for status of `name` subscription of `pair`. Empty `name` matches any subscription of `pair`.
+func (k *Kraken) resolveSubscription(name, pair string, err error) {
+	k.subMx.Lock()
+	defer k.subMx.Unlock()
+
+	for key, waits := range k.subWaits {
+		if key != subscriptionKey(name, pair) && (name != "" || !strings.HasSuffix(key, subscriptionKey("", pair))) {
+			continue
+		}
+		for _, wait := range waits {
+			wait <- err
+		}
+		delete(k.subWaits, key)
+	}
+}
+
 func (k *Kraken) handleEventCancelOrderStatus(data []byte) error {
 	var cancelOrderResponse CancelOrderResponse
 	if err := json.Unmarshal(data, &cancelOrderResponse); err != nil {
@@ -87,10 +146,10 @@ func (k *Kraken) handleEventCancelOrderStatus(data []byte) error {
 		log.Errorf(cancelOrderResponse.ErrorMessage)
 	case StatusOK:
 		log.Debug(" Order successfully cancelled")
-		k.msg <- Update{
+		k.publish(Update{
 			ChannelName: EventCancelOrder,
 			Data:        cancelOrderResponse,
-		}
+		})
 	default:
 		log.Errorf("Unknown status: %s", cancelOrderResponse.Status)
 	}
@@ -108,10 +167,10 @@ func (k *Kraken) handleEventAddOrderStatus(data []byte) error {
 		log.Errorf(addOrderResponse.ErrorMessage)
 	case StatusOK:
 		log.Debug("Order successfully sent")
-		k.msg <- Update{
+		k.publish(Update{
 			ChannelName: EventAddOrder,
 			Data:        addOrderResponse,
-		}
+		})
 	default:
 		log.Errorf("Unknown status: %s", addOrderResponse.Status)
 	}
@@ -129,10 +188,10 @@ func (k *Kraken) handleEventCancellAllStatus(data []byte) error {
 		log.Errorf(cancelAllResponse.ErrorMessage)
 	case StatusOK:
 		log.Debugf("%d orders cancelled", cancelAllResponse.Count)
-		k.msg <- Update{
+		k.publish(Update{
 			ChannelName: EventCancelAllStatus,
 			Data:        cancelAllResponse,
-		}
+		})
 	default:
 		log.Errorf("Unknown status: %s", cancelAllResponse.Status)
 	}
@@ -149,10 +208,10 @@ func (k *Kraken) handleEventCancellAllOrdersAfter(data []byte) error {
 	case StatusError:
 		log.Errorf(cancelAllResponse.ErrorMessage)
 	case StatusOK:
-		k.msg <- Update{
+		k.publish(Update{
 			ChannelName: EventCancelAllOrdersAfter,
 			Data:        cancelAllResponse,
-		}
+		})
 	default:
 		log.Errorf("Unknown status: %s", cancelAllResponse.Status)
 	}
@@ -170,10 +229,10 @@ func (k *Kraken) handleEventEditOrderStatus(data []byte) error {
 		log.Errorf(editOrderResponse.ErrorMessage)
 	case StatusOK:
 		log.Debug("Order successfully edited")
-		k.msg <- Update{
+		k.publish(Update{
 			ChannelName: EventEditOrder,
 			Data:        editOrderResponse,
-		}
+		})
 	default:
 		log.Errorf("Unknown status: %s", editOrderResponse.Status)
 	}
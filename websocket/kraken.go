@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"sync"
@@ -16,19 +17,36 @@ import (
 type Kraken struct {
 	url   string
 	token string
+	v2    bool
+	api   *rest.Kraken
 
 	conn          *websocket.Conn
+	writeMx       sync.Mutex
 	subscriptions map[int64]*SubscriptionStatus
+	subMx         sync.RWMutex
+	sequences     map[string]int64
+	resyncs       map[string]struct{}
+	bookRefs      map[bookRef]int
+	subWaits      map[string][]chan error
+	channels      map[string]channelHandler
+
+	pings     map[int]chan struct{}
+	pingMx    sync.Mutex
+	pingReqID int
 
 	reconnectTimeout time.Duration
 	readTimeout      time.Duration
 	heartbeatTimeout time.Duration
 
-	msg     chan Update
-	connect chan struct{}
-	stop    chan struct{}
+	msg       chan Update
+	listeners []*listener
+	listenMx  sync.RWMutex
+	connect   chan struct{}
+	stop      chan struct{}
 
-	wg sync.WaitGroup
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // New -
@@ -39,11 +57,18 @@ func NewKraken(url string, opts ...KrakenOption) *Kraken {
 		readTimeout:      15 * time.Second,
 		heartbeatTimeout: 10 * time.Second,
 		subscriptions:    make(map[int64]*SubscriptionStatus),
+		sequences:        make(map[string]int64),
+		resyncs:          make(map[string]struct{}),
+		bookRefs:         make(map[bookRef]int),
+		subWaits:         make(map[string][]chan error),
+		pings:            make(map[int]chan struct{}),
 		connect:          make(chan struct{}, 1),
-		msg:              make(chan Update, 1024),
-		stop:             make(chan struct{}, 1),
+		msg:              make(chan Update, updatesBufferSize),
+		stop:             make(chan struct{}),
 	}
 
+	kraken.channels = kraken.defaultChannelHandlers()
+
 	for i := range opts {
 		opts[i](&kraken)
 	}
@@ -80,10 +105,22 @@ func (k *Kraken) dial() error {
 	}
 	defer resp.Body.Close()
 
+	k.writeMx.Lock()
+	defer k.writeMx.Unlock()
+	select {
+	case <-k.stop:
+		// Close has already closed previous connection, so the new one would leak
+		c.Close()
+		return errClosed
+	default:
+	}
 	k.conn = c
 	return nil
 }
 
+// errClosed - is returned by dial if client is closed while connection is being established
+var errClosed = errors.New("client is closed")
+
 func (k *Kraken) managerThread() {
 	defer k.wg.Done()
 
@@ -95,13 +132,20 @@ func (k *Kraken) managerThread() {
 		case <-k.stop:
 			return
 		case <-k.connect:
-			time.Sleep(k.reconnectTimeout)
+			select {
+			case <-k.stop:
+				return
+			case <-time.After(k.reconnectTimeout):
+			}
 
 			log.Warnf("reconnecting...")
 
 			if err := k.dial(); err != nil {
+				if errors.Is(err, errClosed) {
+					return
+				}
 				log.Error(err)
-				k.connect <- struct{}{}
+				k.reconnect()
 				continue
 			}
 
@@ -112,28 +156,41 @@ func (k *Kraken) managerThread() {
 				log.Error(err)
 			}
 		case <-heartbeat.C:
-			if err := k.send(PingRequest{
-				Event: EventPing,
-			}); err != nil {
+			if err := k.ping(0); err != nil {
 				log.Println(err)
-				k.connect <- struct{}{}
+				k.reconnect()
 			}
 		}
 	}
 }
 
-func (k *Kraken) resubscribe() error {
+// reconnect - signals manager thread to reconnect unless client is closed
+func (k *Kraken) reconnect() {
+	select {
+	case k.connect <- struct{}{}:
+	case <-k.stop:
+	}
+}
+
+func (k *Kraken) activeSubscriptions() []SubscriptionStatus {
+	k.subMx.RLock()
+	defer k.subMx.RUnlock()
+
+	subs := make([]SubscriptionStatus, 0, len(k.subscriptions))
 	for _, sub := range k.subscriptions {
+		subs = append(subs, *sub)
+	}
+	return subs
+}
+
+func (k *Kraken) resubscribe() error {
+	for _, sub := range k.activeSubscriptions() {
 		switch sub.Subscription.Name {
 		// Private Channels
 		case ChanOwnTrades, ChanOpenOrders:
 			return k.subscribeToPrivate(sub.Subscription.Name)
 		default:
-			if err := k.send(SubscriptionRequest{
-				Event:        EventSubscribe,
-				Pairs:        []string{sub.Pair},
-				Subscription: sub.Subscription,
-			}); err != nil {
+			if err := k.sendSubscription(EventSubscribe, []string{sub.Pair}, sub.Subscription); err != nil {
 				return err
 			}
 		}
@@ -141,43 +198,221 @@ func (k *Kraken) resubscribe() error {
 	return nil
 }
 
+// updatesBufferSize - buffer size of Listen channel and channels returned by AddListener
+const updatesBufferSize = 1024
+
 // Listen provides an atomic interface for receiving API messages.
 // When a websocket connection is terminated, the publisher channel will close.
+// Updates are delivered in order, so Listen channel and every channel of AddListener must be drained: websocket reading waits for a slow consumer.
 func (k *Kraken) Listen() <-chan Update {
 	return k.msg
 }
 
-// Close - provides an interface for a user initiated shutdown.
-func (k *Kraken) Close() error {
-	for i := 0; i < 2; i++ {
-		k.stop <- struct{}{}
+// listener - additional consumer of updates registered by AddListener
+type listener struct {
+	updates    chan Update
+	done       chan struct{}
+	removeOnce sync.Once
+}
+
+// AddListener - registers additional consumer of updates. Returned channel receives every update sent to Listen channel,
+// so several consumers (e.g. SyncBooks, LiveOrderBook and application code) could share one client.
+// `remove` unregisters listener and closes the channel. The channel is also closed by Close.
+func (k *Kraken) AddListener() (updates <-chan Update, remove func()) {
+	l := &listener{
+		updates: make(chan Update, updatesBufferSize),
+		done:    make(chan struct{}),
+	}
+
+	k.listenMx.Lock()
+	k.listeners = append(k.listeners, l)
+	k.listenMx.Unlock()
+
+	return l.updates, func() {
+		l.removeOnce.Do(func() {
+			// unblocks publish waiting for the listener before the lock is taken
+			close(l.done)
+
+			k.listenMx.Lock()
+			defer k.listenMx.Unlock()
+			for i := range k.listeners {
+				if k.listeners[i] == l {
+					k.listeners = append(k.listeners[:i], k.listeners[i+1:]...)
+					close(l.updates)
+					break
+				}
+			}
+		})
 	}
-	k.wg.Wait()
+}
 
-	if k.conn != nil {
-		if err := k.conn.Close(); err != nil {
-			return err
+// publish - sends update to every listener and then to Listen channel. It returns without delivery when client is closed.
+func (k *Kraken) publish(upd Update) {
+	k.listenMx.RLock()
+	for _, l := range k.listeners {
+		select {
+		case l.updates <- upd:
+		case <-l.done:
+		case <-k.stop:
+			k.listenMx.RUnlock()
+			return
 		}
 	}
+	k.listenMx.RUnlock()
 
-	close(k.stop)
-	close(k.msg)
-	close(k.connect)
+	select {
+	case k.msg <- upd:
+	case <-k.stop:
+	}
+}
+
+// Close - provides an interface for a user initiated shutdown.
+// It unsubscribes from all active subscriptions, closes connection, stops background goroutines and closes Listen channel.
+// Close is idempotent and safe for concurrent use: subsequent calls return result of the first one.
+// It doesn't wait for consumers: updates which are not read from Listen channel or listeners yet are dropped.
+func (k *Kraken) Close() error {
+	k.closeOnce.Do(func() {
+		if err := k.unsubscribeAll(); err != nil {
+			log.Error(err)
+		}
+
+		close(k.stop)
+		k.writeMx.Lock()
+		if k.conn != nil {
+			k.closeErr = k.conn.Close()
+		}
+		k.writeMx.Unlock()
+		k.wg.Wait()
+
+		close(k.msg)
+		close(k.connect)
+
+		k.listenMx.Lock()
+		for _, l := range k.listeners {
+			close(l.updates)
+		}
+		k.listeners = nil
+		k.listenMx.Unlock()
+	})
+	return k.closeErr
+}
+
+func (k *Kraken) unsubscribeAll() error {
+	for _, sub := range k.activeSubscriptions() {
+		switch sub.Subscription.Name {
+		// Private Channels
+		case ChanOwnTrades, ChanOpenOrders:
+			if err := k.send(AuthSubscriptionRequest{
+				Event: EventUnsubscribe,
+				Subs: AuthDataRequest{
+					Name:  sub.Subscription.Name,
+					Token: k.token,
+				},
+			}); err != nil {
+				return err
+			}
+		default:
+			if err := k.sendSubscription(EventUnsubscribe, []string{sub.Pair}, sub.Subscription); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// send - writes `msg` to connection. Connection supports one concurrent writer, so every write goes through send.
 func (k *Kraken) send(msg interface{}) error {
-	if k.conn == nil {
-		return nil
-	}
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
+
+	k.writeMx.Lock()
+	defer k.writeMx.Unlock()
+
+	if k.conn == nil {
+		return nil
+	}
 	log.Tracef("client->server: %s", string(data))
 	return k.conn.WriteMessage(websocket.TextMessage, data)
 }
 
+func (k *Kraken) sendSubscription(event string, pairs []string, sub Subscription) error {
+	if k.v2 {
+		return k.send(newV2SubscriptionRequest(event, pairs, sub))
+	}
+	if event == EventUnsubscribe {
+		return k.send(UnsubscribeRequest{
+			Event:        event,
+			Pairs:        pairs,
+			Subscription: sub,
+		})
+	}
+	return k.send(SubscriptionRequest{
+		Event:        event,
+		Pairs:        pairs,
+		Subscription: sub,
+	})
+}
+
+func (k *Kraken) ping(reqID int) error {
+	if k.v2 {
+		return k.send(V2Request{
+			Method: EventPing,
+			ReqID:  int64(reqID),
+		})
+	}
+	return k.send(PingRequest{
+		Event: EventPing,
+		ReqID: reqID,
+	})
+}
+
+// Ping - sends ping with unique request id and waits for matching pong. Returns round-trip latency.
+// It could be used as connectivity health check. Concurrent pings don't interfere.
+func (k *Kraken) Ping(ctx context.Context) (time.Duration, error) {
+	pong := make(chan struct{})
+
+	k.pingMx.Lock()
+	k.pingReqID++
+	reqID := k.pingReqID
+	k.pings[reqID] = pong
+	k.pingMx.Unlock()
+
+	defer func() {
+		k.pingMx.Lock()
+		delete(k.pings, reqID)
+		k.pingMx.Unlock()
+	}()
+
+	start := time.Now()
+	if err := k.ping(reqID); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-pong:
+		return time.Since(start), nil
+	}
+}
+
+// handlePong - notifies Ping waiting for pong with `reqID`
+func (k *Kraken) handlePong(reqID int) {
+	if reqID == 0 {
+		return
+	}
+
+	k.pingMx.Lock()
+	defer k.pingMx.Unlock()
+
+	if pong, ok := k.pings[reqID]; ok {
+		close(pong)
+		delete(k.pings, reqID)
+	}
+}
+
 func (k *Kraken) listenSocket() {
 	defer k.wg.Done()
 
@@ -197,14 +432,19 @@ func (k *Kraken) listenSocket() {
 		default:
 			_, msg, err := k.conn.ReadMessage()
 			if err != nil {
+				select {
+				case <-k.stop:
+					return
+				default:
+				}
 				log.Error(err)
-				k.connect <- struct{}{}
+				k.reconnect()
 				return
 			}
 
 			if err := k.conn.SetReadDeadline(time.Now().Add(k.readTimeout)); err != nil {
 				log.Error(err)
-				k.connect <- struct{}{}
+				k.reconnect()
 				return
 			}
 
@@ -225,104 +465,217 @@ func (k *Kraken) handleMessage(data []byte) error {
 	case '[':
 		return k.handleChannel(data)
 	case '{':
+		if k.v2 {
+			return k.handleV2Message(data)
+		}
 		return k.handleEvent(data)
 	default:
 		return errors.Errorf("Unexpected message: %s", string(data))
 	}
 }
 
+// Subscribe - subscribes to `sub` channel of `pairs` and waits until Kraken confirms subscription of every pair.
+// Returns ErrSubscription if Kraken rejects any of them, e.g. unknown pair or unsupported depth.
+func (k *Kraken) Subscribe(ctx context.Context, pairs []string, sub Subscription) error {
+	waits := make([]chan error, len(pairs))
+	k.subMx.Lock()
+	for i, pair := range pairs {
+		waits[i] = make(chan error, 1)
+		key := subscriptionKey(sub.Name, pair)
+		k.subWaits[key] = append(k.subWaits[key], waits[i])
+	}
+	k.subMx.Unlock()
+
+	defer func() {
+		k.subMx.Lock()
+		defer k.subMx.Unlock()
+		for i, pair := range pairs {
+			key := subscriptionKey(sub.Name, pair)
+			pending := k.subWaits[key][:0]
+			for _, wait := range k.subWaits[key] {
+				if wait != waits[i] {
+					pending = append(pending, wait)
+				}
+			}
+			if len(pending) == 0 {
+				delete(k.subWaits, key)
+			} else {
+				k.subWaits[key] = pending
+			}
+		}
+	}()
+
+	if err := k.sendSubscription(EventSubscribe, pairs, sub); err != nil {
+		return err
+	}
+	for _, wait := range waits {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-wait:
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // SubscribeTicker - Ticker information includes best ask and best bid prices, 24hr volume, last trade price, volume weighted average price, etc for a given currency pair. A ticker message is published every time a trade or a group of trade happens.
 func (k *Kraken) SubscribeTicker(pairs []string) error {
-	return k.send(SubscriptionRequest{
-		Event: EventSubscribe,
-		Pairs: pairs,
-		Subscription: Subscription{
-			Name: ChanTicker,
-		},
+	return k.sendSubscription(EventSubscribe, pairs, Subscription{
+		Name: ChanTicker,
 	})
 }
 
 // SubscribeCandles - Open High Low Close (Candle) feed for a currency pair and interval period.
 func (k *Kraken) SubscribeCandles(pairs []string, interval int64) error {
-	return k.send(SubscriptionRequest{
-		Event: EventSubscribe,
-		Pairs: pairs,
-		Subscription: Subscription{
-			Name:     ChanCandles,
-			Interval: interval,
-		},
+	return k.sendSubscription(EventSubscribe, pairs, Subscription{
+		Name:     ChanCandles,
+		Interval: interval,
 	})
 }
 
 // SubscribeTrades - Trade feed for a currency pair.
 func (k *Kraken) SubscribeTrades(pairs []string) error {
-	return k.send(SubscriptionRequest{
-		Event: EventSubscribe,
-		Pairs: pairs,
-		Subscription: Subscription{
-			Name: ChanTrades,
-		},
+	return k.sendSubscription(EventSubscribe, pairs, Subscription{
+		Name: ChanTrades,
 	})
 }
 
 // SubscribeSpread - Spread feed to show best bid and ask price for a currency pair
 func (k *Kraken) SubscribeSpread(pairs []string) error {
-	return k.send(SubscriptionRequest{
-		Event: EventSubscribe,
-		Pairs: pairs,
-		Subscription: Subscription{
-			Name: ChanSpread,
-		},
+	return k.sendSubscription(EventSubscribe, pairs, Subscription{
+		Name: ChanSpread,
 	})
 }
 
 // SubscribeBook - Order book levels. On subscription, a snapshot will be published at the specified depth, following the snapshot, level updates will be published.
 func (k *Kraken) SubscribeBook(pairs []string, depth int64) error {
-	return k.send(SubscriptionRequest{
-		Event: EventSubscribe,
-		Pairs: pairs,
-		Subscription: Subscription{
-			Name:  ChanBook,
-			Depth: depth,
-		},
+	return k.sendSubscription(EventSubscribe, pairs, Subscription{
+		Name:  ChanBook,
+		Depth: depth,
 	})
 }
 
 // Unsubscribe - Unsubscribe from single subscription, can specify multiple currency pairs.
 func (k *Kraken) Unsubscribe(channelType string, pairs []string) error {
-	return k.send(UnsubscribeRequest{
-		Event: EventUnsubscribe,
-		Pairs: pairs,
-		Subscription: Subscription{
-			Name: channelType,
-		},
+	return k.sendSubscription(EventUnsubscribe, pairs, Subscription{
+		Name: channelType,
 	})
 }
 
 // UnsubscribeCandles - Unsubscribe from candles subscription, can specify multiple currency pairs.
 func (k *Kraken) UnsubscribeCandles(pairs []string, interval int64) error {
-	return k.send(UnsubscribeRequest{
-		Event: EventUnsubscribe,
-		Pairs: pairs,
-		Subscription: Subscription{
-			Name:     ChanCandles,
-			Interval: interval,
-		},
+	return k.sendSubscription(EventUnsubscribe, pairs, Subscription{
+		Name:     ChanCandles,
+		Interval: interval,
 	})
 }
 
 // UnsubscribeBook - Unsubscribe from order book subscription, can specify multiple currency pairs.
 func (k *Kraken) UnsubscribeBook(pairs []string, depth int64) error {
-	return k.send(UnsubscribeRequest{
-		Event: EventUnsubscribe,
-		Pairs: pairs,
-		Subscription: Subscription{
-			Name:  ChanBook,
-			Depth: depth,
-		},
+	return k.sendSubscription(EventUnsubscribe, pairs, Subscription{
+		Name:  ChanBook,
+		Depth: depth,
 	})
 }
 
+// bookRef - order book subscription shared by consumers, see holdBooks
+type bookRef struct {
+	pair  string
+	depth int64
+}
+
+// holdBooks - registers one more consumer of book subscriptions of `pairs` and returns pairs which had no consumer, so they should be subscribed
+func (k *Kraken) holdBooks(pairs []string, depth int64) []string {
+	k.subMx.Lock()
+	defer k.subMx.Unlock()
+
+	fresh := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		ref := bookRef{pair: pair, depth: depth}
+		if k.bookRefs[ref] == 0 {
+			fresh = append(fresh, pair)
+		}
+		k.bookRefs[ref]++
+	}
+	return fresh
+}
+
+// releaseBooks - unregisters consumer of book subscriptions of `pairs` and returns pairs which have no consumer left, so they should be unsubscribed
+func (k *Kraken) releaseBooks(pairs []string, depth int64) []string {
+	k.subMx.Lock()
+	defer k.subMx.Unlock()
+
+	unused := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		ref := bookRef{pair: pair, depth: depth}
+		if k.bookRefs[ref] == 0 {
+			continue
+		}
+		k.bookRefs[ref]--
+		if k.bookRefs[ref] == 0 {
+			delete(k.bookRefs, ref)
+			unused = append(unused, pair)
+		}
+	}
+	return unused
+}
+
+// Resync - resubscribes to order book of `pair` to receive fresh snapshot, e.g. on checksum mismatch.
+// Book updates of `pair` are dropped until the snapshot arrives. Only one resync of a pair runs at a time: call during resync is no-op.
+// Returns error if there is no book subscription of `pair`.
+func (k *Kraken) Resync(pair string) error {
+	var (
+		depth int64
+		found bool
+	)
+	for _, sub := range k.activeSubscriptions() {
+		if sub.Subscription.Name == ChanBook && sub.Pair == pair {
+			depth, found = sub.Subscription.Depth, true
+			break
+		}
+	}
+	if !found {
+		return errors.Errorf("no book subscription of pair %s", pair)
+	}
+
+	k.subMx.Lock()
+	if _, ok := k.resyncs[pair]; ok {
+		k.subMx.Unlock()
+		return nil
+	}
+	k.resyncs[pair] = struct{}{}
+	k.subMx.Unlock()
+
+	err := k.UnsubscribeBook([]string{pair}, depth)
+	if err == nil {
+		err = k.SubscribeBook([]string{pair}, depth)
+	}
+	if err != nil {
+		k.subMx.Lock()
+		delete(k.resyncs, pair)
+		k.subMx.Unlock()
+	}
+	return err
+}
+
+// skipBookUpdate - reports whether book update of `pair` is received during resync and has to be dropped. Resync is finished by snapshot.
+func (k *Kraken) skipBookUpdate(pair string, isSnapshot bool) bool {
+	k.subMx.Lock()
+	defer k.subMx.Unlock()
+
+	if _, ok := k.resyncs[pair]; !ok {
+		return false
+	}
+	if isSnapshot {
+		delete(k.resyncs, pair)
+		return false
+	}
+	return true
+}
+
 // Authenticate - authenticate in private Websocket API
 func (k *Kraken) Authenticate(key, secret string) error {
 	data, err := rest.New(key, secret).GetWebSocketsToken()
@@ -0,0 +1,308 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer - starts websocket server serving every connection by `handler` and returns its url
+func newTestServer(t *testing.T, handler func(conn *websocket.Conn)) string {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error("could not upgrade connection:", err)
+			return
+		}
+		defer conn.Close()
+		handler(conn)
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestKraken_Close(t *testing.T) {
+	k := NewKraken(ProdBaseURL)
+	k.subscriptions[336] = &SubscriptionStatus{
+		ChannelID:    336,
+		Pair:         "XBT/EUR",
+		Subscription: Subscription{Name: ChanBook, Depth: 10},
+	}
+	k.subscriptions[337] = &SubscriptionStatus{
+		ChannelID:    337,
+		Subscription: Subscription{Name: ChanOwnTrades},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, k.Close())
+		}()
+	}
+	wg.Wait()
+
+	_, ok := <-k.Listen()
+	assert.False(t, ok)
+	_, ok = <-k.stop
+	assert.False(t, ok)
+
+	assert.NotPanics(t, func() {
+		assert.Nil(t, k.Close())
+	})
+}
+
+func TestKraken_Close_undrained(t *testing.T) {
+	url := newTestServer(t, func(conn *websocket.Conn) {
+		trade := []byte(`[0,[["5541.20000","0.15850568","1534614057.321597","s","l",""]],"trade","XBT/USD"]`)
+		for {
+			if err := conn.WriteMessage(websocket.TextMessage, trade); err != nil {
+				return
+			}
+		}
+	})
+
+	k := NewKraken(url)
+	if err := k.Connect(); err != nil {
+		t.Error("could not connect:", err)
+		return
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(k.msg) < cap(k.msg) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, cap(k.msg), len(k.msg))
+
+	closed := make(chan error)
+	go func() {
+		closed <- k.Close()
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("Close is blocked by undrained Listen channel")
+	}
+}
+
+func TestKraken_Close_reconnecting(t *testing.T) {
+	var connections int32
+	url := newTestServer(t, func(conn *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+	})
+
+	k := NewKraken(url, WithReconnectTimeout(200*time.Millisecond))
+	if err := k.Connect(); err != nil {
+		t.Error("could not connect:", err)
+		return
+	}
+	// server drops connection, so manager waits for reconnect
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() {
+		closed <- k.Close()
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("Close is blocked by reconnect")
+	}
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connections))
+}
+
+func TestKraken_AddListener(t *testing.T) {
+	k := NewKraken(ProdBaseURL)
+	first, removeFirst := k.AddListener()
+	second, removeSecond := k.AddListener()
+	defer removeSecond()
+
+	trade := `[0,[["5541.20000","0.15850568","1534614057.321597","s","l",""]],"trade","XBT/USD"]`
+	assert.Nil(t, k.handleMessage([]byte(trade)))
+	for _, updates := range []<-chan Update{first, second, k.Listen()} {
+		upd := <-updates
+		assert.Equal(t, "XBT/USD", upd.Pair)
+		assert.Equal(t, ChanTrades, upd.ChannelName)
+	}
+
+	removeFirst()
+	assert.NotPanics(t, removeFirst)
+	_, ok := <-first
+	assert.False(t, ok)
+
+	assert.Nil(t, k.handleMessage([]byte(trade)))
+	upd := <-second
+	assert.Equal(t, "XBT/USD", upd.Pair)
+
+	assert.Nil(t, k.Close())
+	_, ok = <-second
+	assert.False(t, ok)
+	assert.NotPanics(t, removeSecond)
+}
+
+func TestKraken_Ping(t *testing.T) {
+	const count = 20
+	tests := []struct {
+		name string
+		opts []KrakenOption
+		pong string
+	}{
+		{
+			name: "v1",
+			pong: `{"event":"pong","reqid":%d}`,
+		}, {
+			name: "v2",
+			opts: []KrakenOption{WithProtocolV2()},
+			pong: `{"method":"pong","req_id":%d,"time_in":"2023-09-24T14:10:23.799685Z","time_out":"2023-09-24T14:10:23.799703Z"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// server replies to all pings at once in reverse order
+			url := newTestServer(t, func(conn *websocket.Conn) {
+				ids := make([]int, 0, count)
+				for len(ids) < count {
+					_, data, err := conn.ReadMessage()
+					if err != nil {
+						return
+					}
+					var ping struct {
+						ReqID   int `json:"reqid"`
+						V2ReqID int `json:"req_id"`
+					}
+					if err := json.Unmarshal(data, &ping); err != nil {
+						t.Error("could not parse ping:", err)
+						return
+					}
+					if id := ping.ReqID + ping.V2ReqID; id != 0 {
+						ids = append(ids, id)
+					}
+				}
+				sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+				time.Sleep(5 * time.Millisecond)
+				for _, id := range ids {
+					if err := conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(tt.pong, id))); err != nil {
+						return
+					}
+				}
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			})
+
+			k := NewKraken(url, tt.opts...)
+			if err := k.Connect(); err != nil {
+				t.Error("could not connect:", err)
+				return
+			}
+			defer k.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var wg sync.WaitGroup
+			latencies := make([]time.Duration, count)
+			for i := range latencies {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					latency, err := k.Ping(ctx)
+					assert.Nil(t, err)
+					latencies[i] = latency
+				}(i)
+			}
+			wg.Wait()
+
+			for _, latency := range latencies {
+				assert.True(t, latency >= 5*time.Millisecond && latency < 5*time.Second, "latency %s", latency)
+			}
+			k.pingMx.Lock()
+			assert.Len(t, k.pings, 0)
+			k.pingMx.Unlock()
+		})
+	}
+}
+
+func TestKraken_Ping_timeout(t *testing.T) {
+	k := NewKraken(ProdBaseURL)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := k.Ping(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestKraken_Subscribe(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []KrakenOption
+		status  string
+		wantErr string
+	}{
+		{
+			name:   "v1 subscribed",
+			status: `{"channelID":10001,"channelName":"book-25","event":"subscriptionStatus","pair":"XBT/EUR","status":"subscribed","subscription":{"depth":25,"name":"book"}}`,
+		}, {
+			name:    "v1 error",
+			status:  `{"errorMessage":"Subscription depth not supported","event":"subscriptionStatus","pair":"XBT/EUR","status":"error","subscription":{"depth":42,"name":"book"}}`,
+			wantErr: "subscription to book of XBT/EUR failed: Subscription depth not supported",
+		}, {
+			name:    "v2 error",
+			opts:    []KrakenOption{WithProtocolV2()},
+			status:  `{"error":"Currency pair not supported XBT/EUR","method":"subscribe","success":false,"symbol":"XBT/EUR","time_in":"2023-09-24T14:10:23.799685Z","time_out":"2023-09-24T14:10:23.799703Z"}`,
+			wantErr: "subscription of XBT/EUR failed: Currency pair not supported XBT/EUR",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := NewKraken(ProdBaseURL, tt.opts...)
+
+			// fake server: replies with status once subscription is awaited
+			go func() {
+				for {
+					k.subMx.RLock()
+					waiting := len(k.subWaits) > 0
+					k.subMx.RUnlock()
+					if waiting {
+						assert.Nil(t, k.handleMessage([]byte(tt.status)))
+						return
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			err := k.Subscribe(ctx, []string{"XBT/EUR"}, Subscription{Name: ChanBook, Depth: 25})
+			if tt.wantErr == "" {
+				assert.Nil(t, err)
+				return
+			}
+			if assert.NotNil(t, err) {
+				assert.Equal(t, tt.wantErr, err.Error())
+			}
+			upd := <-k.Listen()
+			_, ok := upd.Data.(*ErrSubscription)
+			assert.True(t, ok)
+			assert.Equal(t, "XBT/EUR", upd.Pair)
+			assert.Len(t, k.subWaits, 0)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package websocket
 import (
 	"time"
 
+	"github.com/BenKnigge/go_kraken/rest"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -36,3 +37,18 @@ func WithHeartbeatTimeout(timeout time.Duration) KrakenOption {
 		k.heartbeatTimeout = timeout
 	}
 }
+
+// WithProtocolV2 - use v2 websocket protocol (`ProdV2BaseURL`) for public `book`, `ticker`, `ohlc` and `trade` channels.
+// Book updates are sent to Listen channel as OrderBookUpdate, other channels as V2Ticker, V2Candle and V2Trade. Update.Pair contains v2 symbol (e.g. `BTC/USD`).
+func WithProtocolV2() KrakenOption {
+	return func(k *Kraken) {
+		k.v2 = true
+	}
+}
+
+// WithRESTClient - add REST client used by LiveOrderBook to request pairs and order book snapshot. Default: client without credentials.
+func WithRESTClient(api *rest.Kraken) KrakenOption {
+	return func(k *Kraken) {
+		k.api = api
+	}
+}
@@ -2,11 +2,17 @@ package websocket
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"hash/crc32"
+	"strconv"
 	"strings"
 
+	"github.com/BenKnigge/go_kraken/rest"
+	"github.com/ericlagergren/decimal"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 )
 
 // OrderBook -
@@ -41,17 +47,30 @@ func (o *OrderBook) ApplyUpdate(upd OrderBookUpdate, verify bool) error {
 
 	if verify && !upd.IsSnapshot {
 		if cs := o.Checksum(); cs != upd.CheckSum {
-			return errors.Errorf("invalid checksum: local %s != remote %s", cs, upd.CheckSum)
+			return &ErrChecksumMismatch{Local: cs, Remote: upd.CheckSum}
 		}
 	}
 	return nil
 }
 
-// Checksum - computes order book checksum. Details https://docs.kraken.com/websockets/#book-checksum
+// ErrChecksumMismatch - error returned by ApplyUpdate if local order book checksum differs from Kraken's one. Order book should be resynced, see Kraken.Resync.
+type ErrChecksumMismatch struct {
+	Local  string
+	Remote string
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("invalid checksum: local %s != remote %s", e.Local, e.Remote)
+}
+
+// checksumDepth - count of best levels of each side included into order book checksum
+const checksumDepth = 10
+
+// Checksum - computes order book checksum of top 10 levels of each side regardless of subscribed depth. Details https://docs.kraken.com/websockets/#book-checksum
 func (o *OrderBook) Checksum() string {
 	var str bytes.Buffer
-	str.Write(o.Asks.checksum())
-	str.Write(o.Bids.checksum())
+	str.Write(o.Asks.checksumTop(checksumDepth))
+	str.Write(o.Bids.checksumTop(checksumDepth))
 	return fmt.Sprint(crc32.ChecksumIEEE(str.Bytes()))
 }
 
@@ -64,3 +83,239 @@ func (o *OrderBook) String() string {
 	builder.WriteString(o.Bids.String())
 	return builder.String()
 }
+
+// LiveOrderBook - creates order book of `pair` seeded from REST snapshot and kept up to date by websocket book updates until `ctx` is done.
+// `pair` is a REST name (e.g. `XXBTZEUR`), altname or websocket name of the pair. REST client is set by WithRESTClient.
+// It's a method of websocket client, because the websocket package depends on the rest one.
+//
+// Book subscription is confirmed before REST snapshot is requested. Updates received meanwhile are buffered:
+// levels not newer than the snapshot are dropped and the rest are applied after seeding, so no update is lost. Returned book is already populated.
+// Updates are received by own listener (see AddListener), so Listen channel is not affected.
+// Book subscription is shared with SyncBooks and other LiveOrderBook calls of the same pair and depth: it's unsubscribed when the last of them is done.
+func (k *Kraken) LiveOrderBook(ctx context.Context, pair string, depth int) (*OrderBook, error) {
+	api := k.api
+	if api == nil {
+		api = rest.NewPublic()
+	}
+
+	name, info, err := api.PairInfo(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	fresh := k.holdBooks([]string{info.WSName}, int64(depth))
+	updates, remove := k.AddListener()
+	live := &liveBook{
+		k:       k,
+		pair:    info.WSName,
+		depth:   depth,
+		feed:    bookFeed{book: NewOrderBook(depth, info.PairDecimals, info.LotDecimals)},
+		updates: updates,
+		seeds:   make(chan rest.OrderBook),
+		seeded:  make(chan error),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer cancel()
+		defer remove()
+		live.run(ctx)
+	}()
+
+	if len(fresh) > 0 {
+		if err := k.Subscribe(ctx, fresh, Subscription{Name: ChanBook, Depth: int64(depth)}); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	snapshotDepth := int64(depth)
+	if snapshotDepth > rest.MaxOrderBookDepth {
+		snapshotDepth = rest.MaxOrderBookDepth
+	}
+	snapshots, err := api.GetOrderBook(name, snapshotDepth)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	snapshot, ok := snapshots[name]
+	if !ok {
+		cancel()
+		return nil, errors.Errorf("no order book of pair %s in REST response", name)
+	}
+
+	select {
+	case live.seeds <- snapshot:
+	case <-live.done:
+		return nil, errors.Errorf("order book of pair %s is stopped before seeding", name)
+	}
+	if err := <-live.seeded; err != nil {
+		cancel()
+		return nil, err
+	}
+	return live.feed.book, nil
+}
+
+// liveBook - state of order book maintained by LiveOrderBook
+type liveBook struct {
+	k     *Kraken
+	pair  string
+	depth int
+	feed  bookFeed
+
+	updates <-chan Update
+	// buffer - websocket updates received before REST snapshot
+	buffer []OrderBookUpdate
+
+	seeds  chan rest.OrderBook
+	seeded chan error
+	done   chan struct{}
+}
+
+func (l *liveBook) run(ctx context.Context) {
+	defer close(l.done)
+
+	seeds := l.seeds
+	for {
+		select {
+		case <-ctx.Done():
+			if unused := l.k.releaseBooks([]string{l.pair}, int64(l.depth)); len(unused) > 0 {
+				if err := l.k.UnsubscribeBook(unused, int64(l.depth)); err != nil {
+					log.Error(err)
+				}
+			}
+			return
+		case snapshot := <-seeds:
+			seeds = nil
+			l.seeded <- l.seed(snapshot)
+		case upd, ok := <-l.updates:
+			if !ok {
+				return
+			}
+			data, ok := upd.Data.(OrderBookUpdate)
+			if !ok || upd.Pair != l.pair {
+				continue
+			}
+			if seeds != nil {
+				l.buffer = append(l.buffer, data)
+				continue
+			}
+			l.apply(data)
+		}
+	}
+}
+
+// seed - applies REST `snapshot` and then buffered updates newer than the snapshot
+func (l *liveBook) seed(snapshot rest.OrderBook) error {
+	// updates already published for the listener precede the snapshot as well
+	for queued := true; queued; {
+		select {
+		case upd, ok := <-l.updates:
+			if data, isBook := upd.Data.(OrderBookUpdate); ok && isBook && upd.Pair == l.pair {
+				l.buffer = append(l.buffer, data)
+			}
+			queued = ok
+		default:
+			queued = false
+		}
+	}
+
+	upd := newOrderBookUpdate(snapshot)
+	if err := l.feed.apply(upd); err != nil {
+		return err
+	}
+	since := snapshotTime(upd)
+	for _, data := range l.buffer {
+		// REST snapshot is requested after subscription is confirmed, so it's not older than websocket one
+		if data.IsSnapshot {
+			continue
+		}
+		if data = data.newerThan(since); len(data.Asks)+len(data.Bids) > 0 {
+			l.apply(data)
+		}
+	}
+	l.buffer = nil
+	return nil
+}
+
+func (l *liveBook) apply(data OrderBookUpdate) {
+	err := l.feed.apply(data)
+	if err == nil {
+		return
+	}
+	log.Error(err)
+	var mismatch *ErrChecksumMismatch
+	if errors.As(err, &mismatch) {
+		if err := l.k.Resync(l.pair); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// snapshotTime - returns time of the latest level of snapshot
+func snapshotTime(snapshot OrderBookUpdate) *decimal.Big {
+	latest := new(decimal.Big)
+	for _, items := range [][]OrderBookItem{snapshot.Asks, snapshot.Bids} {
+		for _, item := range items {
+			if t, ok := new(decimal.Big).SetString(item.Time.String()); ok && t.Cmp(latest) > 0 {
+				latest = t
+			}
+		}
+	}
+	return latest
+}
+
+// newerThan - returns update with levels updated after `since` only
+func (upd OrderBookUpdate) newerThan(since *decimal.Big) OrderBookUpdate {
+	filter := func(items []OrderBookItem) []OrderBookItem {
+		result := make([]OrderBookItem, 0, len(items))
+		for _, item := range items {
+			if t, ok := new(decimal.Big).SetString(item.Time.String()); !ok || t.Cmp(since) > 0 {
+				result = append(result, item)
+			}
+		}
+		return result
+	}
+	upd.Asks = filter(upd.Asks)
+	upd.Bids = filter(upd.Bids)
+	return upd
+}
+
+func newOrderBookUpdate(snapshot rest.OrderBook) OrderBookUpdate {
+	upd := OrderBookUpdate{
+		Asks:       make([]OrderBookItem, len(snapshot.Asks)),
+		Bids:       make([]OrderBookItem, len(snapshot.Bids)),
+		IsSnapshot: true,
+	}
+	for i, item := range snapshot.Asks {
+		upd.Asks[i] = newOrderBookItem(item)
+	}
+	for i, item := range snapshot.Bids {
+		upd.Bids[i] = newOrderBookItem(item)
+	}
+	return upd
+}
+
+func newOrderBookItem(item rest.OrderBookItem) OrderBookItem {
+	price, volume := item.RawPrice, item.RawVolume
+	if price == "" {
+		price = strconv.FormatFloat(item.Price, 'f', -1, 64)
+	}
+	if volume == "" {
+		volume = strconv.FormatFloat(item.Volume, 'f', -1, 64)
+	}
+	timestamp := item.RawTimestamp
+	if timestamp == "" {
+		timestamp = strconv.FormatInt(item.Timestamp, 10)
+	}
+	return OrderBookItem{
+		Price:  json.Number(price),
+		Volume: json.Number(volume),
+		Time:   json.Number(timestamp),
+	}
+}
+
+func (o *OrderBook) reset() {
+	o.Asks.Clear()
+	o.Bids.Clear()
+}
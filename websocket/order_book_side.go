@@ -2,12 +2,14 @@ package websocket
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/BenKnigge/go_kraken/rest"
 	"github.com/ericlagergren/decimal"
 )
 
@@ -22,8 +24,9 @@ func (a byPrice) Len() int           { return len(a) }
 func (a byPrice) Less(i, j int) bool { return a[i].Price.Cmp(a[j].Price) == -1 }
 func (a byPrice) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
-func newOrderBookLevels(m map[string]orderBookLevel, asc bool) []orderBookLevel {
-	result := make([]orderBookLevel, 0)
+// newOrderBookLevels - returns sorted levels of `m`. Levels are appended to `buf[:0]`, so its backing array is reused if it has enough capacity.
+func newOrderBookLevels(buf []orderBookLevel, m map[string]orderBookLevel, asc bool) []orderBookLevel {
+	result := buf[:0]
 
 	for _, value := range m {
 		result = append(result, value)
@@ -41,7 +44,7 @@ func newOrderBookLevels(m map[string]orderBookLevel, asc bool) []orderBookLevel
 // OrderBookSide -
 type OrderBookSide struct {
 	m               map[string]orderBookLevel
-	sorted          []orderBookLevel
+	sorted          []orderBookLevel // reused by every update to avoid allocation per frame
 	depth           int
 	pricePrecision  int
 	volumePrecision int
@@ -62,39 +65,67 @@ func newOrderBookSide(depth, pricePrecision, volumePrecision int, isAsk bool) *O
 	}
 }
 
+// NewOrderBookSideFromItems - creates order book side loaded from REST order book snapshot `items`. Levels beyond `depth` are trimmed.
+// See NewOrderBook for details of arguments.
+func NewOrderBookSideFromItems(items []rest.OrderBookItem, depth, pricePrecision, volumePrecision int, isAsk bool) (*OrderBookSide, error) {
+	updates := make([]OrderBookItem, len(items))
+	for i := range items {
+		updates[i] = newOrderBookItem(items[i])
+	}
+	side := newOrderBookSide(depth, pricePrecision, volumePrecision, isAsk)
+	if err := side.applyUpdates(updates); err != nil {
+		return nil, err
+	}
+	return side, nil
+}
+
+// stringFixed - formats `big` with exactly `precision` decimals rounding half to even, so prices and checksums match Kraken
 func stringFixed(big *decimal.Big, precision int) string {
+	return formatFixed(big, precision, decimal.ToNearestEven)
+}
+
+// formatFixed - formats `big` with exactly `precision` decimals rounded by `mode` regardless of rounding mode of `big` context
+func formatFixed(big *decimal.Big, precision int, mode decimal.RoundingMode) string {
+	rounded := new(decimal.Big).Copy(big)
+	decimal.Context{Precision: decimal.MaxPrecision, RoundingMode: mode}.Quantize(rounded, precision)
 	fmtStr := "%." + strconv.Itoa(precision) + "f"
-	return fmt.Sprintf(fmtStr, big)
+	return fmt.Sprintf(fmtStr, rounded)
 }
 
-func (o *OrderBookSide) applyUpdate(upd OrderBookItem) error {
-	flValue, err := upd.Volume.Float64()
-	if err != nil {
-		return err
+func (o *OrderBookSide) parseUpdate(upd OrderBookItem) (string, orderBookLevel, error) {
+	volume := new(decimal.Big)
+	if err := volume.UnmarshalText([]byte(upd.Volume.String())); err != nil {
+		return "", orderBookLevel{}, err
 	}
 
 	price := decimal.WithPrecision(o.pricePrecision)
-	err = price.UnmarshalText([]byte(upd.Price.String()))
-	if err != nil {
-		return err
+	if err := price.UnmarshalText([]byte(upd.Price.String())); err != nil {
+		return "", orderBookLevel{}, err
 	}
 
-	key := stringFixed(price, o.pricePrecision)
+	return stringFixed(price, o.pricePrecision), orderBookLevel{
+		Price:  price,
+		Volume: volume,
+	}, nil
+}
 
-	o.mx.Lock()
-	if flValue == 0 {
+// setLevel - sets or removes (if volume is zero) level. Lock must be held.
+func (o *OrderBookSide) setLevel(key string, level orderBookLevel) {
+	if level.Volume.Sign() == 0 {
 		delete(o.m, key)
 	} else {
-		v := &decimal.Big{}
-		err = v.UnmarshalText([]byte(upd.Price.String()))
-		if err != nil {
-			return err
-		}
-		o.m[key] = orderBookLevel{
-			Price:  price,
-			Volume: v,
-		}
+		o.m[key] = level
 	}
+}
+
+func (o *OrderBookSide) applyUpdate(upd OrderBookItem) error {
+	key, level, err := o.parseUpdate(upd)
+	if err != nil {
+		return err
+	}
+
+	o.mx.Lock()
+	o.setLevel(key, level)
 	o.mx.Unlock()
 	return nil
 }
@@ -107,17 +138,63 @@ func (o *OrderBookSide) applyUpdates(updates []OrderBookItem) error {
 	}
 
 	o.mx.Lock()
-	levels := newOrderBookLevels(o.m, o.isAsk)
-	for _, level := range levels[o.depth:] {
-		delete(o.m, stringFixed(level.Price, o.pricePrecision))
+	o.sortLevels()
+	o.mx.Unlock()
+
+	return nil
+}
+
+// ApplyUpdatesAtomic - applies all `updates` or none of them. If any update is invalid, the side is left unchanged, so it never reflects a partial frame.
+func (o *OrderBookSide) ApplyUpdatesAtomic(updates []OrderBookItem) error {
+	keys := make([]string, len(updates))
+	levels := make([]orderBookLevel, len(updates))
+	for i := range updates {
+		key, level, err := o.parseUpdate(updates[i])
+		if err != nil {
+			return err
+		}
+		keys[i], levels[i] = key, level
+	}
+
+	o.mx.Lock()
+	for i := range keys {
+		o.setLevel(keys[i], levels[i])
 	}
-	o.sorted = levels[:o.depth]
+	o.sortLevels()
 	o.mx.Unlock()
 
 	return nil
 }
 
-// Get - receives volume by price. If not exists returns false
+// sortLevels - rebuilds sorted levels in place and trims side to depth. Lock must be held.
+func (o *OrderBookSide) sortLevels() {
+	levels := newOrderBookLevels(o.sorted, o.m, o.isAsk)
+	if len(levels) > o.depth {
+		for _, level := range levels[o.depth:] {
+			delete(o.m, stringFixed(level.Price, o.pricePrecision))
+		}
+		levels = levels[:o.depth]
+	}
+	o.sorted = levels
+}
+
+// Depth - returns count of price levels in the side
+func (o *OrderBookSide) Depth() int {
+	o.mx.RLock()
+	defer o.mx.RUnlock()
+
+	return len(o.sorted)
+}
+
+// Clear - removes all levels of the side
+func (o *OrderBookSide) Clear() {
+	o.mx.Lock()
+	o.m = make(map[string]orderBookLevel)
+	o.sorted = make([]orderBookLevel, 0)
+	o.mx.Unlock()
+}
+
+// Get - receives copy of volume by price. If not exists returns false
 func (o *OrderBookSide) Get(price *decimal.Big) (*decimal.Big, bool) {
 	o.mx.RLock()
 	defer o.mx.RUnlock()
@@ -127,23 +204,36 @@ func (o *OrderBookSide) Get(price *decimal.Big) (*decimal.Big, bool) {
 	if !ok {
 		return decimal.New(0, 0), ok
 	}
-	return level.Volume, ok
+	return new(decimal.Big).Copy(level.Volume), ok
 }
 
-// Range - ranges by order book side from best price to depth
+// Range - ranges by order book side from best price to depth. Handler receives copies of price and volume.
 func (o *OrderBookSide) Range(handler func(price, volume *decimal.Big) error) error {
 	o.mx.RLock()
 	defer o.mx.RUnlock()
 
 	for i := range o.sorted {
-		if err := handler(o.sorted[i].Price, o.sorted[i].Volume); err != nil {
+		if err := handler(new(decimal.Big).Copy(o.sorted[i].Price), new(decimal.Big).Copy(o.sorted[i].Volume)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// Best - returns best price and volume at this price. If order book is not initialized it returns Zero
+// RangeN - ranges by at most `n` levels of order book side from best price. Handler receives level index and copies of price and volume.
+// Iteration stops when handler returns false.
+func (o *OrderBookSide) RangeN(n int, handler func(i int, price, volume *decimal.Big) bool) {
+	o.mx.RLock()
+	defer o.mx.RUnlock()
+
+	for i := 0; i < n && i < len(o.sorted); i++ {
+		if !handler(i, new(decimal.Big).Copy(o.sorted[i].Price), new(decimal.Big).Copy(o.sorted[i].Volume)) {
+			return
+		}
+	}
+}
+
+// Best - returns copies of best price and volume at this price. If order book is not initialized it returns Zero
 func (o *OrderBookSide) Best() (*decimal.Big, *decimal.Big) {
 	o.mx.RLock()
 	defer o.mx.RUnlock()
@@ -151,28 +241,28 @@ func (o *OrderBookSide) Best() (*decimal.Big, *decimal.Big) {
 	if len(o.sorted) == 0 {
 		return decimal.New(0, 0), decimal.New(0, 0)
 	}
-	return o.sorted[0].Price, o.sorted[0].Volume
+	return new(decimal.Big).Copy(o.sorted[0].Price), new(decimal.Big).Copy(o.sorted[0].Volume)
 }
 
-func (o *OrderBookSide) checksum() []byte {
+// checksumTop - returns checksum input of at most `n` best levels. Decimal point and leading zeros are removed from price and volume.
+func (o *OrderBookSide) checksumTop(n int) []byte {
 	o.mx.RLock()
 	defer o.mx.RUnlock()
 
 	var str bytes.Buffer
-	for _, level := range o.sorted {
-		price := stringFixed(level.Price, o.pricePrecision)
-		price = strings.Replace(price, ".", "", 1)
-		price = strings.TrimLeft(price, "0")
-		str.WriteString(price)
-
-		volume := stringFixed(level.Volume, o.volumePrecision)
-		volume = strings.Replace(volume, ".", "", 1)
-		volume = strings.TrimLeft(volume, "0")
-		str.WriteString(volume)
+	for i := 0; i < n && i < len(o.sorted); i++ {
+		str.WriteString(checksumValue(o.sorted[i].Price, o.pricePrecision))
+		str.WriteString(checksumValue(o.sorted[i].Volume, o.volumePrecision))
 	}
 	return str.Bytes()
 }
 
+func checksumValue(value *decimal.Big, precision int) string {
+	str := stringFixed(value, precision)
+	str = strings.Replace(str, ".", "", 1)
+	return strings.TrimLeft(str, "0")
+}
+
 // String -
 func (o *OrderBookSide) String() string {
 	o.mx.RLock()
@@ -196,3 +286,52 @@ func (o *OrderBookSide) String() string {
 	}
 	return str.String()
 }
+
+type orderBookSideJSON struct {
+	Depth           int         `json:"depth"`
+	PricePrecision  int         `json:"price_precision"`
+	VolumePrecision int         `json:"volume_precision"`
+	IsAsk           bool        `json:"is_ask"`
+	Levels          [][2]string `json:"levels"`
+}
+
+// MarshalJSON - serializes sorted levels, depth and precisions, so order book side could be saved and restored later
+func (o *OrderBookSide) MarshalJSON() ([]byte, error) {
+	o.mx.RLock()
+	defer o.mx.RUnlock()
+
+	levels := make([][2]string, len(o.sorted))
+	for i := range o.sorted {
+		levels[i] = [2]string{o.sorted[i].Price.String(), o.sorted[i].Volume.String()}
+	}
+	return json.Marshal(orderBookSideJSON{
+		Depth:           o.depth,
+		PricePrecision:  o.pricePrecision,
+		VolumePrecision: o.volumePrecision,
+		IsAsk:           o.isAsk,
+		Levels:          levels,
+	})
+}
+
+// UnmarshalJSON - restores order book side serialized by MarshalJSON. It must not be called on side which is in use.
+func (o *OrderBookSide) UnmarshalJSON(data []byte) error {
+	var tmp orderBookSideJSON
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+
+	updates := make([]OrderBookItem, len(tmp.Levels))
+	for i, level := range tmp.Levels {
+		updates[i] = OrderBookItem{
+			Price:  json.Number(level[0]),
+			Volume: json.Number(level[1]),
+		}
+	}
+
+	side := newOrderBookSide(tmp.Depth, tmp.PricePrecision, tmp.VolumePrecision, tmp.IsAsk)
+	if err := side.applyUpdates(updates); err != nil {
+		return err
+	}
+	*o = *side
+	return nil
+}
@@ -0,0 +1,285 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/BenKnigge/go_kraken/rest"
+	"github.com/ericlagergren/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderBookSide_applyUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		volume    json.Number
+		wantExist bool
+	}{
+		{
+			name:      "tiny nonzero volume keeps level",
+			volume:    "1E-400",
+			wantExist: true,
+		}, {
+			name:      "zero volume deletes level",
+			volume:    "0.00000000",
+			wantExist: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			side := newOrderBookSide(10, 5, 8, true)
+			if err := side.applyUpdate(OrderBookItem{Price: "50251.20000", Volume: "1.00000000"}); err != nil {
+				t.Error("could not apply update:", err)
+				return
+			}
+			if err := side.applyUpdate(OrderBookItem{Price: "50251.20000", Volume: tt.volume}); err != nil {
+				t.Error("could not apply update:", err)
+				return
+			}
+
+			volume, ok := side.Get(decimal.New(502512, 1))
+			assert.Equal(t, tt.wantExist, ok)
+			if tt.wantExist {
+				assert.Equal(t, 0, volume.Cmp(new(decimal.Big).SetMantScale(1, 400)))
+			}
+		})
+	}
+}
+
+func TestOrderBookSide_BestReturnsCopy(t *testing.T) {
+	side := newOrderBookSide(10, 1, 8, true)
+	if err := side.applyUpdates([]OrderBookItem{{Price: "50251.2", Volume: "1.5"}}); err != nil {
+		t.Error("could not apply updates:", err)
+		return
+	}
+
+	price, volume := side.Best()
+	price.Add(price, decimal.New(1, 0))
+	volume.Add(volume, decimal.New(1, 0))
+
+	price, volume = side.Best()
+	assert.Equal(t, 0, price.Cmp(decimal.New(502512, 1)))
+	assert.Equal(t, 0, volume.Cmp(decimal.New(15, 1)))
+
+	got, ok := side.Get(decimal.New(502512, 1))
+	assert.True(t, ok)
+	got.Add(got, decimal.New(1, 0))
+
+	got, _ = side.Get(decimal.New(502512, 1))
+	assert.Equal(t, 0, got.Cmp(decimal.New(15, 1)))
+}
+
+func TestOrderBookSide_RangeN(t *testing.T) {
+	side := newOrderBookSide(10, 1, 8, false)
+	updates := make([]OrderBookItem, 0, 5)
+	for _, price := range []string{"100.1", "100.5", "100.3", "100.2", "100.4"} {
+		updates = append(updates, OrderBookItem{Price: json.Number(price), Volume: "1"})
+	}
+	if err := side.applyUpdates(updates); err != nil {
+		t.Error("could not apply updates:", err)
+		return
+	}
+
+	indices := make([]int, 0)
+	prices := make([]string, 0)
+	side.RangeN(5, func(i int, price, volume *decimal.Big) bool {
+		indices = append(indices, i)
+		prices = append(prices, price.String())
+		return i < 2
+	})
+	assert.Equal(t, []int{0, 1, 2}, indices)
+	assert.Equal(t, []string{"100.5", "100.4", "100.3"}, prices)
+
+	count := 0
+	side.RangeN(2, func(i int, price, volume *decimal.Big) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 2, count)
+}
+
+func TestOrderBookSide_Range(t *testing.T) {
+	side := newOrderBookSide(10, 1, 8, true)
+	if err := side.applyUpdates([]OrderBookItem{{Price: "100.1", Volume: "1"}, {Price: "100.2", Volume: "2"}}); err != nil {
+		t.Error("could not apply updates:", err)
+		return
+	}
+
+	err := side.Range(func(price, volume *decimal.Big) error {
+		price.Add(price, decimal.New(1, 0))
+		volume.Add(volume, decimal.New(1, 0))
+		return nil
+	})
+	assert.Nil(t, err)
+
+	prices := make([]string, 0)
+	volumes := make([]string, 0)
+	err = side.Range(func(price, volume *decimal.Big) error {
+		prices = append(prices, price.String())
+		volumes = append(volumes, volume.String())
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"100.1", "100.2"}, prices)
+	assert.Equal(t, []string{"1", "2"}, volumes)
+}
+
+func TestOrderBookSide_JSON(t *testing.T) {
+	book := NewOrderBook(10, 1, 8)
+	err := book.ApplyUpdate(OrderBookUpdate{
+		Asks: []OrderBookItem{
+			{Price: "50252.1", Volume: "1.5"},
+			{Price: "50253.4", Volume: "0.25"},
+		},
+		Bids: []OrderBookItem{
+			{Price: "50251.2", Volume: "2"},
+		},
+		IsSnapshot: true,
+	}, false)
+	if err != nil {
+		t.Error("could not apply snapshot:", err)
+		return
+	}
+
+	data, err := json.Marshal(book)
+	if err != nil {
+		t.Error("could not marshal order book:", err)
+		return
+	}
+
+	var restored OrderBook
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Error("could not unmarshal order book:", err)
+		return
+	}
+
+	assert.Equal(t, book.Checksum(), restored.Checksum())
+	assert.Equal(t, book.String(), restored.String())
+
+	volume, ok := restored.Asks.Get(decimal.New(502534, 1))
+	assert.True(t, ok)
+	assert.Equal(t, 0, volume.Cmp(decimal.New(25, 2)))
+
+	// restored side is usable for further updates
+	if err := restored.Bids.applyUpdates([]OrderBookItem{{Price: "50251.5", Volume: "1"}}); err != nil {
+		t.Error("could not apply update:", err)
+		return
+	}
+	price, _ := restored.Bids.Best()
+	assert.Equal(t, 0, price.Cmp(decimal.New(502515, 1)))
+}
+
+func TestNewOrderBookSideFromItems(t *testing.T) {
+	items := []rest.OrderBookItem{
+		{Price: 50253.5, Volume: 0.5, Timestamp: 1638472269},
+		{Price: 50252.1, Volume: 1.5, Timestamp: 1638472269},
+		{Price: 50254, Volume: 2, Timestamp: 1638472269},
+		{Price: 50252.9, Volume: 0.25, Timestamp: 1638472269},
+		{Price: 50255.3, Volume: 3, Timestamp: 1638472269},
+	}
+
+	side, err := NewOrderBookSideFromItems(items, 10, 1, 8, true)
+	if err != nil {
+		t.Error("could not create order book side:", err)
+		return
+	}
+	price, volume := side.Best()
+	assert.Equal(t, 0, price.Cmp(decimal.New(502521, 1)))
+	assert.Equal(t, 0, volume.Cmp(decimal.New(15, 1)))
+	assert.Equal(t, 5, side.Depth())
+
+	trimmed, err := NewOrderBookSideFromItems(items, 3, 1, 8, false)
+	if err != nil {
+		t.Error("could not create order book side:", err)
+		return
+	}
+	price, _ = trimmed.Best()
+	assert.Equal(t, 0, price.Cmp(decimal.New(502553, 1)))
+	assert.Equal(t, 3, trimmed.Depth())
+}
+
+func TestOrderBookSide_ApplyUpdatesAtomic(t *testing.T) {
+	side := newOrderBookSide(10, 1, 8, true)
+	err := side.ApplyUpdatesAtomic([]OrderBookItem{
+		{Price: "50252.1", Volume: "1.5"},
+		{Price: "50253.4", Volume: "0.25"},
+	})
+	if err != nil {
+		t.Error("could not apply updates:", err)
+		return
+	}
+	before := side.String()
+
+	err = side.ApplyUpdatesAtomic([]OrderBookItem{
+		{Price: "50252.1", Volume: "0"},
+		{Price: "50254.0", Volume: "not a number"},
+		{Price: "50251.9", Volume: "3"},
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, before, side.String())
+	assert.Equal(t, 2, side.Depth())
+	price, _ := side.Best()
+	assert.Equal(t, 0, price.Cmp(decimal.New(502521, 1)))
+}
+
+func Test_formatFixed(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		precision int
+		mode      decimal.RoundingMode
+		want      string
+	}{
+		{name: "half to even rounds up odd digit", value: "0.123455", precision: 5, mode: decimal.ToNearestEven, want: "0.12346"},
+		{name: "half to even keeps even digit", value: "0.123465", precision: 5, mode: decimal.ToNearestEven, want: "0.12346"},
+		{name: "above half", value: "0.1234650001", precision: 5, mode: decimal.ToNearestEven, want: "0.12347"},
+		{name: "negative", value: "-0.123455", precision: 5, mode: decimal.ToNearestEven, want: "-0.12346"},
+		{name: "integer half", value: "2.5", precision: 0, mode: decimal.ToNearestEven, want: "2"},
+		{name: "padding", value: "2.5", precision: 3, mode: decimal.ToNearestEven, want: "2.500"},
+		{name: "truncation", value: "0.123459", precision: 5, mode: decimal.ToZero, want: "0.12345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, ok := new(decimal.Big).SetString(tt.value)
+			if !assert.True(t, ok) {
+				return
+			}
+			assert.Equal(t, tt.want, formatFixed(value, tt.precision, tt.mode))
+		})
+	}
+
+	t.Run("context of value is ignored", func(t *testing.T) {
+		value := decimal.WithContext(decimal.Context{RoundingMode: decimal.AwayFromZero})
+		value.SetString("0.123465")
+		assert.Equal(t, "0.12346", stringFixed(value, 5))
+		assert.Equal(t, "0.123465", value.String())
+	})
+}
+
+func BenchmarkApplyUpdates(b *testing.B) {
+	const depth = 100
+	snapshot := make([]OrderBookItem, depth)
+	for i := range snapshot {
+		snapshot[i] = OrderBookItem{
+			Price:  json.Number(strconv.FormatFloat(30000+float64(i)/10, 'f', 1, 64)),
+			Volume: json.Number("1.00000000"),
+		}
+	}
+	side := newOrderBookSide(depth, 1, 8, true)
+	if err := side.applyUpdates(snapshot); err != nil {
+		b.Fatal(err)
+	}
+	updates := []OrderBookItem{
+		{Price: "30000.5", Volume: "2.50000000"},
+		{Price: "30001.0", Volume: "0.75000000"},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := side.applyUpdates(updates); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BenKnigge/go_kraken/rest"
+	"github.com/ericlagergren/decimal"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKraken_LiveOrderBook(t *testing.T) {
+	const (
+		subscribed   = `{"channelID":336,"channelName":"book-10","event":"subscriptionStatus","pair":"XBT/EUR","status":"subscribed","subscription":{"depth":10,"name":"book"}}`
+		unsubscribed = `{"channelID":336,"channelName":"book-10","event":"subscriptionStatus","pair":"XBT/EUR","status":"unsubscribed","subscription":{"depth":10,"name":"book"}}`
+		// websocket snapshot is older than REST one
+		snapshot = `[336,{"as":[["50260.00000","1.00000000","1638472268.000000"]],"bs":[["50250.00000","3.00000000","1638472268.000000"]]},"book-10","XBT/EUR"]`
+		// delta sent before REST snapshot was taken
+		stale = `[336,{"b":[["50250.00000","3.00000000","1638472268.500000"]],"c":"1"},"book-10","XBT/EUR"]`
+	)
+	fresh := fmt.Sprintf(`[336,{"b":[["50251.30000","0.50000000","1638472270.482087"]],"c":"%s"},"book-10","XBT/EUR"]`, checksumOf(
+		[2]string{"50252.10000", "1.50000000"},
+		[2]string{"50251.30000", "0.50000000"},
+		[2]string{"50251.20000", "2.00000000"},
+	))
+	live := fmt.Sprintf(`[336,{"a":[["50253.00000","1.00000000","1638472271.482087"]],"c":"%s"},"book-10","XBT/EUR"]`, checksumOf(
+		[2]string{"50252.10000", "1.50000000"},
+		[2]string{"50253.00000", "1.00000000"},
+		[2]string{"50251.30000", "0.50000000"},
+		[2]string{"50251.20000", "2.00000000"},
+	))
+
+	var (
+		events  []string
+		served  = make(chan struct{})
+		fetched = make(chan struct{})
+	)
+	wsURL := newTestServer(t, func(conn *websocket.Conn) {
+		defer close(served)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req PingRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				t.Error("could not parse request:", err)
+				return
+			}
+			var replies []string
+			switch req.Event {
+			case EventSubscribe:
+				replies = []string{subscribed, snapshot, stale, fresh}
+			case EventUnsubscribe:
+				replies = []string{unsubscribed}
+			case EventPing:
+				// ping of the test is a signal to send live update
+				replies = []string{live, fmt.Sprintf(`{"event":"pong","reqid":%d}`, req.ReqID)}
+			}
+			if req.Event != EventPing {
+				events = append(events, req.Event)
+			}
+			for _, reply := range replies {
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(reply)); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	restServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0/public/AssetPairs":
+			fmt.Fprint(w, `{"error":[],"result":{"XXBTZEUR":{"altname":"XBTEUR","wsname":"XBT/EUR","pair_decimals":5,"lot_decimals":8}}}`)
+		case "/0/public/Depth":
+			assert.Equal(t, "XXBTZEUR", r.URL.Query().Get("pair"))
+			// snapshot is taken after websocket updates are received
+			<-fetched
+			fmt.Fprint(w, `{"error":[],"result":{"XXBTZEUR":{"asks":[["50252.10000","1.500",1638472269]],"bids":[["50251.20000","2.000",1638472269]]}}}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer restServer.Close()
+
+	k := NewKraken(wsURL, WithRESTClient(rest.NewWithOptions("", "", rest.WithBaseURL(restServer.URL))))
+	if err := k.Connect(); err != nil {
+		t.Error("could not connect:", err)
+		return
+	}
+	go func() {
+		// updates are sent to Listen channel after listeners
+		var once sync.Once
+		for upd := range k.Listen() {
+			if data, ok := upd.Data.(OrderBookUpdate); ok && len(data.Bids) == 1 && data.Bids[0].Price == "50251.30000" {
+				once.Do(func() { close(fetched) })
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	book, err := k.LiveOrderBook(ctx, "XBTEUR", 10)
+	if err != nil {
+		t.Error("could not create live order book:", err)
+		cancel()
+		return
+	}
+
+	// REST snapshot and fresh buffered delta are applied, websocket snapshot and stale delta are dropped
+	assert.Equal(t, 1, book.Asks.Depth())
+	assert.Equal(t, 2, book.Bids.Depth())
+	price, volume := book.Asks.Best()
+	assert.Equal(t, 0, price.Cmp(decimal.New(5025210, 2)))
+	assert.Equal(t, 0, volume.Cmp(decimal.New(15, 1)))
+	price, volume = book.Bids.Best()
+	assert.Equal(t, 0, price.Cmp(decimal.New(5025130, 2)))
+	assert.Equal(t, 0, volume.Cmp(decimal.New(5, 1)))
+	_, ok := book.Bids.Get(decimal.New(50250, 0))
+	assert.False(t, ok)
+
+	if _, err := k.Ping(ctx); err != nil {
+		t.Error("could not ping:", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && book.Asks.Depth() < 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, ok = book.Asks.Get(decimal.New(50253, 0))
+	assert.True(t, ok)
+
+	cancel()
+	deadline = time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && len(k.activeSubscriptions()) > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, k.Close())
+	<-served
+
+	// checksums matched, so order book was not resynced
+	assert.Equal(t, []string{EventSubscribe, EventUnsubscribe}, events)
+}
+
+func TestKraken_LiveOrderBook_sharedSubscription(t *testing.T) {
+	const (
+		subscribed   = `{"channelID":336,"channelName":"book-10","event":"subscriptionStatus","pair":"XBT/EUR","status":"subscribed","subscription":{"depth":10,"name":"book"}}`
+		unsubscribed = `{"channelID":336,"channelName":"book-10","event":"subscriptionStatus","pair":"XBT/EUR","status":"unsubscribed","subscription":{"depth":10,"name":"book"}}`
+		snapshot     = `[336,{"as":[["50252.10000","1.50000000","1638472269.000000"]],"bs":[["50251.20000","2.00000000","1638472269.000000"]]},"book-10","XBT/EUR"]`
+	)
+	var (
+		mx     sync.Mutex
+		events []string
+	)
+	eventsSent := func() []string {
+		mx.Lock()
+		defer mx.Unlock()
+		return append([]string(nil), events...)
+	}
+	wsURL := newTestServer(t, func(conn *websocket.Conn) {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req PingRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				t.Error("could not parse request:", err)
+				return
+			}
+			var replies []string
+			switch req.Event {
+			case EventSubscribe:
+				replies = []string{subscribed, snapshot}
+			case EventUnsubscribe:
+				replies = []string{unsubscribed}
+			case EventPing:
+				replies = []string{fmt.Sprintf(`{"event":"pong","reqid":%d}`, req.ReqID)}
+			}
+			if req.Event != EventPing {
+				mx.Lock()
+				events = append(events, req.Event)
+				mx.Unlock()
+			}
+			for _, reply := range replies {
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(reply)); err != nil {
+					return
+				}
+			}
+		}
+	})
+	restServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0/public/AssetPairs":
+			fmt.Fprint(w, `{"error":[],"result":{"XXBTZEUR":{"altname":"XBTEUR","wsname":"XBT/EUR","pair_decimals":5,"lot_decimals":8}}}`)
+		case "/0/public/Depth":
+			fmt.Fprint(w, `{"error":[],"result":{"XXBTZEUR":{"asks":[["50252.10000","1.500",1638472269]],"bids":[["50251.20000","2.000",1638472269]]}}}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer restServer.Close()
+
+	k := NewKraken(wsURL, WithRESTClient(rest.NewPublic(rest.WithBaseURL(restServer.URL))))
+	if err := k.Connect(); err != nil {
+		t.Error("could not connect:", err)
+		return
+	}
+	defer k.Close()
+	go func() {
+		for range k.Listen() {
+		}
+	}()
+
+	store := NewBookStore(10)
+	store.Track("XBT/EUR", 5, 8)
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+	if err := k.SyncBooks(syncCtx, store); err != nil {
+		t.Error("could not sync books:", err)
+		return
+	}
+
+	liveCtx, stopLive := context.WithCancel(context.Background())
+	book, err := k.LiveOrderBook(liveCtx, "XBTEUR", 10)
+	if err != nil {
+		t.Error("could not create live order book:", err)
+		stopLive()
+		return
+	}
+	assert.Equal(t, 1, book.Asks.Depth())
+
+	// the book is still synced by SyncBooks, so it's not unsubscribed
+	stopLive()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := k.Ping(context.Background()); err != nil {
+		t.Error("could not ping:", err)
+	}
+	assert.Equal(t, []string{EventSubscribe}, eventsSent())
+
+	stopSync()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && len(eventsSent()) < 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, []string{EventSubscribe, EventUnsubscribe}, eventsSent())
+}
+
+func TestOrderBook_Checksum(t *testing.T) {
+	// example book and checksum published at https://docs.kraken.com/websockets/#book-checksum
+	asks := []OrderBookItem{
+		{Price: "0.05005", Volume: "0.00000500"}, {Price: "0.05010", Volume: "0.00000500"},
+		{Price: "0.05015", Volume: "0.00000500"}, {Price: "0.05020", Volume: "0.00000500"},
+		{Price: "0.05025", Volume: "0.00000500"}, {Price: "0.05030", Volume: "0.00000500"},
+		{Price: "0.05035", Volume: "0.00000500"}, {Price: "0.05040", Volume: "0.00000500"},
+		{Price: "0.05045", Volume: "0.00000500"}, {Price: "0.05050", Volume: "0.00000500"},
+	}
+	bids := []OrderBookItem{
+		{Price: "0.05000", Volume: "0.00000500"}, {Price: "0.04995", Volume: "0.00000500"},
+		{Price: "0.04990", Volume: "0.00000500"}, {Price: "0.04980", Volume: "0.00000500"},
+		{Price: "0.04975", Volume: "0.00000500"}, {Price: "0.04970", Volume: "0.00000500"},
+		{Price: "0.04965", Volume: "0.00000500"}, {Price: "0.04960", Volume: "0.00000500"},
+		{Price: "0.04955", Volume: "0.00000500"}, {Price: "0.04950", Volume: "0.00000500"},
+	}
+	tests := []struct {
+		name  string
+		depth int
+		asks  []OrderBookItem
+		bids  []OrderBookItem
+	}{
+		{
+			name:  "depth 10",
+			depth: 10,
+			asks:  asks,
+			bids:  bids,
+		}, {
+			name:  "depth 25 uses top 10 levels only",
+			depth: 25,
+			asks:  append(append([]OrderBookItem{}, asks...), OrderBookItem{Price: "0.05055", Volume: "0.00000500"}),
+			bids:  append(append([]OrderBookItem{}, bids...), OrderBookItem{Price: "0.04945", Volume: "0.00000500"}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := NewOrderBook(tt.depth, 5, 8)
+			err := book.ApplyUpdate(OrderBookUpdate{
+				Asks:       tt.asks,
+				Bids:       tt.bids,
+				IsSnapshot: true,
+			}, false)
+			if err != nil {
+				t.Error("could not apply snapshot:", err)
+				return
+			}
+			assert.Equal(t, "974947235", book.Checksum())
+
+			levels := make([][2]string, 0, 20)
+			for _, item := range append(append([]OrderBookItem{}, asks...), bids...) {
+				levels = append(levels, [2]string{item.Price.String(), item.Volume.String()})
+			}
+			assert.Equal(t, "974947235", checksumOf(levels...))
+		})
+	}
+}
+
+// checksumOf - computes Kraken checksum of levels given as received from Kraken: asks from the best, then bids from the best.
+// Details https://docs.kraken.com/websockets/#book-checksum
+func checksumOf(levels ...[2]string) string {
+	var buf strings.Builder
+	for _, level := range levels {
+		for _, value := range level {
+			buf.WriteString(strings.TrimLeft(strings.ReplaceAll(value, ".", ""), "0"))
+		}
+	}
+	return fmt.Sprint(crc32.ChecksumIEEE([]byte(buf.String())))
+}
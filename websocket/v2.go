@@ -0,0 +1,261 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// V2 message types
+const (
+	V2TypeSnapshot = "snapshot"
+	V2TypeUpdate   = "update"
+)
+
+// V2 service channels
+const (
+	ChanV2Heartbeat = "heartbeat"
+	ChanV2Status    = "status"
+)
+
+// V2Request - data structure of v2 request envelope
+type V2Request struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+	ReqID  int64       `json:"req_id,omitempty"`
+}
+
+// V2SubscriptionParams - parameters of v2 subscribe and unsubscribe requests
+type V2SubscriptionParams struct {
+	Channel  string   `json:"channel"`
+	Symbol   []string `json:"symbol"`
+	Depth    int64    `json:"depth,omitempty"`
+	Interval int64    `json:"interval,omitempty"`
+}
+
+// V2SubscriptionResult - result of v2 subscribe and unsubscribe requests. It's sent for every requested symbol.
+type V2SubscriptionResult struct {
+	Channel  string `json:"channel"`
+	Symbol   string `json:"symbol"`
+	Depth    int64  `json:"depth,omitempty"`
+	Interval int64  `json:"interval,omitempty"`
+}
+
+// V2Message - data structure of v2 message envelope. Channel messages have `channel`, `type` and `data` fields, responses on requests have `method`, `success`, `result` and `error` fields.
+type V2Message struct {
+	Channel string          `json:"channel"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+	Method  string          `json:"method"`
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+	Error   string          `json:"error"`
+	ReqID   int64           `json:"req_id"`
+	Symbol  string          `json:"symbol"`
+}
+
+// V2PriceLevel - price level of v2 book
+type V2PriceLevel struct {
+	Price json.Number `json:"price"`
+	Qty   json.Number `json:"qty"`
+}
+
+// V2Book - data structure of v2 book snapshot or update. It's sent to Listen channel as OrderBookUpdate.
+type V2Book struct {
+	Symbol    string         `json:"symbol"`
+	Bids      []V2PriceLevel `json:"bids"`
+	Asks      []V2PriceLevel `json:"asks"`
+	Checksum  json.Number    `json:"checksum"`
+	Timestamp string         `json:"timestamp"`
+}
+
+// V2Ticker - data structure of v2 ticker
+type V2Ticker struct {
+	Symbol    string      `json:"symbol"`
+	Bid       json.Number `json:"bid"`
+	BidQty    json.Number `json:"bid_qty"`
+	Ask       json.Number `json:"ask"`
+	AskQty    json.Number `json:"ask_qty"`
+	Last      json.Number `json:"last"`
+	Volume    json.Number `json:"volume"`
+	VWAP      json.Number `json:"vwap"`
+	Low       json.Number `json:"low"`
+	High      json.Number `json:"high"`
+	Change    json.Number `json:"change"`
+	ChangePct json.Number `json:"change_pct"`
+}
+
+// V2Candle - data structure of v2 ohlc
+type V2Candle struct {
+	Symbol        string      `json:"symbol"`
+	Open          json.Number `json:"open"`
+	High          json.Number `json:"high"`
+	Low           json.Number `json:"low"`
+	Close         json.Number `json:"close"`
+	VWAP          json.Number `json:"vwap"`
+	Volume        json.Number `json:"volume"`
+	Trades        int64       `json:"trades"`
+	IntervalBegin string      `json:"interval_begin"`
+	Interval      int64       `json:"interval"`
+}
+
+// V2Trade - data structure of v2 trade
+type V2Trade struct {
+	Symbol    string      `json:"symbol"`
+	Side      string      `json:"side"`
+	Price     json.Number `json:"price"`
+	Qty       json.Number `json:"qty"`
+	OrdType   string      `json:"ord_type"`
+	TradeID   int64       `json:"trade_id"`
+	Timestamp string      `json:"timestamp"`
+}
+
+func newV2SubscriptionRequest(event string, pairs []string, sub Subscription) V2Request {
+	return V2Request{
+		Method: event,
+		Params: V2SubscriptionParams{
+			Channel:  sub.Name,
+			Symbol:   pairs,
+			Depth:    sub.Depth,
+			Interval: sub.Interval,
+		},
+	}
+}
+
+func (book V2Book) toOrderBookUpdate(isSnapshot bool) OrderBookUpdate {
+	upd := OrderBookUpdate{
+		Asks:       make([]OrderBookItem, len(book.Asks)),
+		Bids:       make([]OrderBookItem, len(book.Bids)),
+		CheckSum:   book.Checksum.String(),
+		IsSnapshot: isSnapshot,
+	}
+	for i, level := range book.Asks {
+		upd.Asks[i] = OrderBookItem{Price: level.Price, Volume: level.Qty}
+	}
+	for i, level := range book.Bids {
+		upd.Bids[i] = OrderBookItem{Price: level.Price, Volume: level.Qty}
+	}
+	return upd
+}
+
+func (k *Kraken) handleV2Message(data []byte) error {
+	var msg V2Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+
+	if msg.Method != "" {
+		return k.handleV2Response(msg)
+	}
+
+	switch msg.Channel {
+	case ChanV2Heartbeat, ChanV2Status:
+	case ChanBook:
+		var books []V2Book
+		if err := json.Unmarshal(msg.Data, &books); err != nil {
+			return err
+		}
+		for _, book := range books {
+			if k.skipBookUpdate(book.Symbol, msg.Type == V2TypeSnapshot) {
+				continue
+			}
+			k.publish(msg.toUpdate(book.Symbol, book.toOrderBookUpdate(msg.Type == V2TypeSnapshot)))
+		}
+	case ChanTicker:
+		var tickers []V2Ticker
+		if err := json.Unmarshal(msg.Data, &tickers); err != nil {
+			return err
+		}
+		for _, ticker := range tickers {
+			k.publish(msg.toUpdate(ticker.Symbol, ticker))
+		}
+	case ChanCandles:
+		var candles []V2Candle
+		if err := json.Unmarshal(msg.Data, &candles); err != nil {
+			return err
+		}
+		for _, candle := range candles {
+			k.publish(msg.toUpdate(candle.Symbol, candle))
+		}
+	case ChanTrades:
+		var trades []V2Trade
+		if err := json.Unmarshal(msg.Data, &trades); err != nil {
+			return err
+		}
+		for _, trade := range trades {
+			k.publish(msg.toUpdate(trade.Symbol, trade))
+		}
+	default:
+		log.Warnf("unknown channel: %s", data)
+	}
+	return nil
+}
+
+func (msg V2Message) toUpdate(symbol string, data interface{}) Update {
+	return Update{
+		Data:        data,
+		ChannelName: msg.Channel,
+		Pair:        symbol,
+	}
+}
+
+func (k *Kraken) handleV2Response(msg V2Message) error {
+	if msg.Method == EventPong {
+		k.handlePong(int(msg.ReqID))
+		return nil
+	}
+
+	if !msg.Success {
+		log.Errorf("%s: %s", msg.Method, msg.Error)
+		if msg.Method == EventSubscribe && msg.Symbol != "" {
+			err := &ErrSubscription{
+				Pair:    msg.Symbol,
+				Message: msg.Error,
+			}
+			k.resolveSubscription("", msg.Symbol, err)
+			k.publish(Update{
+				Pair: msg.Symbol,
+				Data: err,
+			})
+		}
+		return nil
+	}
+
+	if msg.Method == EventSubscribe || msg.Method == EventUnsubscribe {
+		var result V2SubscriptionResult
+		if err := json.Unmarshal(msg.Result, &result); err != nil {
+			return err
+		}
+		k.trackV2Subscription(msg.Method, result.Symbol, Subscription{
+			Name:     result.Channel,
+			Depth:    result.Depth,
+			Interval: result.Interval,
+		})
+		if msg.Method == EventSubscribe {
+			k.resolveSubscription(result.Channel, result.Symbol, nil)
+		}
+	}
+	return nil
+}
+
+// trackV2Subscription - stores v2 subscription, so it's restored on reconnect. V2 has no channel ids, so key is derived from subscription parameters.
+func (k *Kraken) trackV2Subscription(method, symbol string, sub Subscription) {
+	key := int64(crc32.ChecksumIEEE([]byte(fmt.Sprintf("%s|%s|%d|%d", sub.Name, symbol, sub.Depth, sub.Interval))))
+
+	k.subMx.Lock()
+	defer k.subMx.Unlock()
+
+	if method == EventUnsubscribe {
+		delete(k.subscriptions, key)
+		return
+	}
+	k.subscriptions[key] = &SubscriptionStatus{
+		ChannelID:    key,
+		Event:        method,
+		Status:       SubscriptionStatusSubscribed,
+		Pair:         symbol,
+		Subscription: sub,
+	}
+}
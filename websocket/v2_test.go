@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKraken_handleV2Message_book(t *testing.T) {
+	k := NewKraken(ProdV2BaseURL, WithProtocolV2())
+
+	snapshot := `{"channel":"book","type":"snapshot","data":[{"symbol":"MATIC/USD","bids":[{"price":0.5666,"qty":4831.75496356},{"price":0.5665,"qty":6658.22734739}],"asks":[{"price":0.5668,"qty":4410.79769741}],"checksum":2439117997}]}`
+	update := `{"channel":"book","type":"update","data":[{"symbol":"MATIC/USD","bids":[{"price":0.5657,"qty":1098.3947558}],"asks":[],"checksum":2114181697,"timestamp":"2023-10-06T17:35:55.440295Z"}]}`
+
+	tests := []struct {
+		name string
+		msg  string
+		want OrderBookUpdate
+	}{
+		{
+			name: "snapshot",
+			msg:  snapshot,
+			want: OrderBookUpdate{
+				Asks: []OrderBookItem{{Price: "0.5668", Volume: "4410.79769741"}},
+				Bids: []OrderBookItem{
+					{Price: "0.5666", Volume: "4831.75496356"},
+					{Price: "0.5665", Volume: "6658.22734739"},
+				},
+				CheckSum:   "2439117997",
+				IsSnapshot: true,
+			},
+		}, {
+			name: "update",
+			msg:  update,
+			want: OrderBookUpdate{
+				Asks:     []OrderBookItem{},
+				Bids:     []OrderBookItem{{Price: "0.5657", Volume: "1098.3947558"}},
+				CheckSum: "2114181697",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := k.handleMessage([]byte(tt.msg)); err != nil {
+				t.Error("could not handle message:", err)
+				return
+			}
+			assert.Len(t, k.msg, 1)
+			upd := <-k.Listen()
+			assert.Equal(t, ChanBook, upd.ChannelName)
+			assert.Equal(t, "MATIC/USD", upd.Pair)
+			assert.Equal(t, tt.want, upd.Data)
+		})
+	}
+}
+
+func TestKraken_handleV2Message_subscription(t *testing.T) {
+	k := NewKraken(ProdV2BaseURL, WithProtocolV2())
+
+	subscribed := `{"method":"subscribe","result":{"channel":"book","depth":10,"snapshot":true,"symbol":"BTC/USD"},"success":true,"time_in":"2023-10-06T17:35:55.000000Z","time_out":"2023-10-06T17:35:55.000001Z"}`
+	if err := k.handleMessage([]byte(subscribed)); err != nil {
+		t.Error("could not handle message:", err)
+		return
+	}
+	subs := k.activeSubscriptions()
+	if assert.Len(t, subs, 1) {
+		assert.Equal(t, "BTC/USD", subs[0].Pair)
+		assert.Equal(t, Subscription{Name: ChanBook, Depth: 10}, subs[0].Subscription)
+	}
+
+	unsubscribed := `{"method":"unsubscribe","result":{"channel":"book","depth":10,"symbol":"BTC/USD"},"success":true}`
+	if err := k.handleMessage([]byte(unsubscribed)); err != nil {
+		t.Error("could not handle message:", err)
+		return
+	}
+	assert.Len(t, k.activeSubscriptions(), 0)
+}
+
+func Test_newV2SubscriptionRequest(t *testing.T) {
+	req := newV2SubscriptionRequest(EventSubscribe, []string{"BTC/USD"}, Subscription{Name: ChanBook, Depth: 25})
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Error("could not marshal request:", err)
+		return
+	}
+	assert.Equal(t, `{"method":"subscribe","params":{"channel":"book","symbol":["BTC/USD"],"depth":25}}`, string(data))
+}